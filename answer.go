@@ -0,0 +1,57 @@
+package bottalker
+
+import (
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+)
+
+// CallbackCommand is command pressing inline button, bot may answer such press
+// with toast, alert or URL instead of sending or editing message
+type CallbackCommand interface {
+	BotCommandType
+	TriggerWithAnswer() (*tdlib.Message, *tdlib.CallbackQueryAnswer, *BotError) // trigerring command, answer is returned along with message with pressed button
+}
+
+var (
+	_ CallbackCommand = (*BotCommandPayload)(nil)
+	_ CallbackCommand = (*BotCommandButton)(nil)
+)
+
+// answerReply is callback answer queued for message handler
+type answerReply struct {
+	answer *tdlib.CallbackQueryAnswer
+	msg    *tdlib.Message // message with pressed button
+}
+
+// handleAnswer passes callback answer to replies like any other bot update, `m` is message with pressed button
+// and `start` is time of button press; empty answers only acknowledge button press, so they are skipped.
+// `bct` is nil for ad-hoc presses, their answers are attributed like any other bot update.
+// Answer is queued for message handler, so trigger doesn't wait for `Replies` consumer, which may trigger commands itself
+func (b *Bot) handleAnswer(bct BotCommandType, m *tdlib.Message, answer *tdlib.CallbackQueryAnswer, start time.Time) {
+	if answer == nil || (answer.Text == "" && answer.URL == "") {
+		return
+	}
+	if bct != nil {
+		// answer is reply to this very trigger, even if it was triggered manually
+		cr := bct.getReport()
+		cr.Lock()
+		cr.lastTrigger = start
+		cr.Unlock()
+		b.report.Lock()
+		b.report.lastCommand = bct
+		b.report.Unlock()
+	}
+
+	b.RLock()
+	answerCh := b.answerCh
+	b.RUnlock()
+	if answerCh == nil {
+		return
+	}
+	select {
+	case answerCh <- &answerReply{answer: answer, msg: m}:
+	default:
+		b.getLogger().Warn("Answers queue is full, answer skipped")
+	}
+}
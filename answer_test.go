@@ -0,0 +1,128 @@
+package bottalker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+func TestCallbackAnswer(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Balance", bottalkertest.InlineKeyboard(
+		bottalkertest.Row(bottalkertest.CallbackButton("BTC", "/bal_btc")),
+	))
+	qbot.OnCallback("/bal_btc").Answer("BTC: 1.0", true)
+
+	replies := make(chan *bottalker.Reply, 100)
+	bcp := &bottalker.BotCommandPayload{
+		BotCommand: bottalker.BotCommand{
+			Data:    []byte("/bal_btc"),
+			Passive: true,
+		},
+	}
+	b := &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: time.Hour,
+		Replies:     replies,
+		Commands:    []bottalker.BotCommandType{bcp},
+	}
+	runBottalker(t, srv, b)
+
+	// payload is set on bot start
+	var answer *tdlib.CallbackQueryAnswer
+	waitFor(t, 5*time.Second, func() bool {
+		var bErr *bottalker.BotError
+		_, answer, bErr = bcp.TriggerWithAnswer()
+		return bErr == nil
+	})
+	if answer.Text != "BTC: 1.0" || !answer.ShowAlert {
+		t.Errorf("unexpected answer: %+v", answer)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case reply := <-replies:
+			if reply.Kind == bottalker.ReplyCallbackAnswer {
+				if reply.Text != "BTC: 1.0" || reply.Command != bcp {
+					t.Errorf("unexpected reply: %+v", reply)
+				}
+				if _, ok := reply.Update.(*tdlib.CallbackQueryAnswer); !ok {
+					t.Errorf("unexpected update: %T", reply.Update)
+				}
+				if reply.Message == nil || len(reply.Buttons) != 1 || reply.Buttons[0].Text != "BTC" {
+					t.Errorf("unexpected message with pressed button: %+v", reply.Message)
+				}
+				if reply.TriggeredAt.IsZero() || reply.Latency() < 0 {
+					t.Errorf("unexpected timing: %v, %v", reply.TriggeredAt, reply.Latency())
+				}
+				if status := b.Status(); status.Commands[0].LastReply != "BTC: 1.0" {
+					t.Errorf("unexpected last reply: %q", status.Commands[0].LastReply)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatal("answer wasn't passed to replies")
+		}
+	}
+}
+
+func TestCallbackAnswerUnbufferedReplies(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Balance", bottalkertest.InlineKeyboard(
+		bottalkertest.Row(bottalkertest.CallbackButton("BTC", "/bal_btc")),
+	))
+	qbot.OnCallback("/bal_btc").Answer("BTC: 1.0", false)
+
+	replies := make(chan *bottalker.Reply)
+	bcp := &bottalker.BotCommandPayload{
+		BotCommand: bottalker.BotCommand{
+			Data:    []byte("/bal_btc"),
+			Passive: true,
+		},
+	}
+	runBottalker(t, srv, &bottalker.Bot{
+		Label:    "QBot",
+		ChatID:   qbot.ID,
+		Replies:  replies,
+		Commands: []bottalker.BotCommandType{bcp},
+	})
+	waitFor(t, 5*time.Second, func() bool {
+		_, _, bErr := bcp.TriggerWithAnswer()
+		return bErr == nil
+	})
+
+	// consumer triggering command from replies loop must not wait for itself
+	var answers int
+	timeout := time.After(5 * time.Second)
+	for answers < 3 {
+		select {
+		case reply := <-replies:
+			if reply.Kind != bottalker.ReplyCallbackAnswer {
+				continue
+			}
+			answers++
+			triggered := make(chan *bottalker.BotError, 1)
+			go func() {
+				_, _, bErr := bcp.TriggerWithAnswer()
+				triggered <- bErr
+			}()
+			select {
+			case bErr := <-triggered:
+				if bErr != nil {
+					t.Fatal(bErr)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("trigger waits for replies consumer")
+			}
+		case <-timeout:
+			t.Fatalf("answers passed to replies: %d", answers)
+		}
+	}
+}
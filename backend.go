@@ -0,0 +1,189 @@
+package bottalker
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+
+	"github.com/Arman92/go-tdlib"
+)
+
+// TelegramBackend is everything bottalker needs from Telegram
+// Method signatures follow `tdlib.Client` so tdlib can be plugged in as is,
+// implement it yourself if you want to run bots without tdlib (tests, mocks, etc.)
+type TelegramBackend interface {
+	SendMessage(chatID int64, messageThreadID int64, replyToMessageID int64, options *tdlib.MessageSendOptions, replyMarkup tdlib.ReplyMarkup, inputMessageContent tdlib.InputMessageContent) (*tdlib.Message, error)
+	GetCallbackQueryAnswer(chatID int64, messageID int64, payload tdlib.CallbackQueryPayload) (*tdlib.CallbackQueryAnswer, error)
+	GetMessage(chatID int64, messageID int64) (*tdlib.Message, error)
+	GetChat(chatID int64) (*tdlib.Chat, error)
+	GetChats(chatList tdlib.ChatList, offsetOrder tdlib.JSONInt64, offsetChatID int64, limit int32) (*tdlib.Chats, error)
+	GetChatHistory(chatID int64, fromMessageID int64, offset int32, limit int32, onlyLocal bool) (*tdlib.Messages, error)
+	GetChatMessageByDate(chatID int64, date int32) (*tdlib.Message, error)
+
+	Authorize() (tdlib.AuthorizationState, error)
+	SendPhoneNumber(phoneNumber string) (tdlib.AuthorizationState, error)
+	SendAuthCode(code string) (tdlib.AuthorizationState, error)
+	SendAuthPassword(password string) (tdlib.AuthorizationState, error)
+	AddProxy(server string, port int32, enable bool, typeParam tdlib.ProxyType) (*tdlib.Proxy, error)
+
+	AddEventReceiver(msgInstance tdlib.TdMessage, filterFunc tdlib.EventFilterFunc, channelCapacity int) *EventReceiver // subscribe to updates of `msgInstance` type
+	DestroyInstance()                                                                                                   // shutdown backend, it shouldn't be used after that
+}
+
+// EventReceiver is subscription to backend updates
+type EventReceiver struct {
+	Chan   <-chan tdlib.TdMessage // updates passed through filter; closed after `Close()`
+	closer func()                 // backend specific unsubscribe
+	once   sync.Once
+}
+
+// NewEventReceiver creates EventReceiver, `closer` must stop delivering updates and close `ch`
+func NewEventReceiver(ch <-chan tdlib.TdMessage, closer func()) *EventReceiver {
+	return &EventReceiver{
+		Chan:   ch,
+		closer: closer,
+	}
+}
+
+// Close unsubscribes receiver, safe to call more than once
+func (er *EventReceiver) Close() {
+	er.once.Do(func() {
+		if er.closer != nil {
+			er.closer()
+		}
+	})
+}
+
+// maxPendingUpdates is how many updates TdlibBackend keeps for busy subscriber, newer ones are dropped
+const maxPendingUpdates = 10000
+
+// TdlibBackend is TelegramBackend implemented over tdlib
+type TdlibBackend struct {
+	*tdlib.Client
+	receivers    []*tdlibReceiver // active subscriptions
+	receiverLock sync.Mutex
+	dispatchOnce sync.Once
+}
+
+// tdlibReceiver is subscription multiplexed over tdlib raw updates channel
+//
+// Updates are queued and passed to `out` by receiver's own goroutine, so slow subscriber never blocks
+// tdlib receive loop, which delivers responses to requests as well
+type tdlibReceiver struct {
+	msgType     string // tdlib `@type` of updates
+	msgInstance tdlib.TdMessage
+	filterFunc  tdlib.EventFilterFunc
+	out         chan tdlib.TdMessage
+	pending     []tdlib.TdMessage // updates waiting for `out`
+	wake        chan struct{}     // signals pending updates to `pump`
+	done        chan struct{}     // closed on unsubscribe
+	sync.Mutex
+}
+
+// NewTdlibBackend creates tdlib client with given config
+func NewTdlibBackend(config tdlib.Config) *TdlibBackend {
+	return &TdlibBackend{
+		Client: tdlib.NewClient(config),
+	}
+}
+
+// AddEventReceiver subscribes to tdlib updates
+//
+// tdlib has no way to remove receiver, so all subscriptions are served from
+// single raw updates channel and closed receiver is really removed
+func (tb *TdlibBackend) AddEventReceiver(msgInstance tdlib.TdMessage, filterFunc tdlib.EventFilterFunc, channelCapacity int) *EventReceiver {
+	tb.dispatchOnce.Do(func() {
+		go tb.dispatch(tb.Client.GetRawUpdatesChannel(100))
+	})
+
+	r := &tdlibReceiver{
+		msgType:     msgInstance.MessageType(),
+		msgInstance: msgInstance,
+		filterFunc:  filterFunc,
+		out:         make(chan tdlib.TdMessage, channelCapacity),
+		wake:        make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+	tb.receiverLock.Lock()
+	tb.receivers = append(tb.receivers, r)
+	tb.receiverLock.Unlock()
+	go r.pump()
+
+	return NewEventReceiver(r.out, func() {
+		tb.receiverLock.Lock()
+		for i, receiver := range tb.receivers {
+			if receiver == r {
+				tb.receivers = append(tb.receivers[:i:i], tb.receivers[i+1:]...)
+				break
+			}
+		}
+		tb.receiverLock.Unlock()
+		// `pump` closes `out` once it's done
+		close(r.done)
+	})
+}
+
+// dispatch decodes raw updates and queues them to matching receivers
+func (tb *TdlibBackend) dispatch(updates <-chan tdlib.UpdateMsg) {
+	for update := range updates {
+		msgType, _ := update.Data["@type"].(string)
+		tb.receiverLock.Lock()
+		receivers := append([]*tdlibReceiver(nil), tb.receivers...)
+		tb.receiverLock.Unlock()
+
+		for _, r := range receivers {
+			if r.msgType == msgType {
+				r.deliver(update.Raw)
+			}
+		}
+	}
+}
+
+// deliver decodes update into receiver type and queues it if filter passes it, it never blocks
+func (r *tdlibReceiver) deliver(raw []byte) {
+	msg := reflect.New(reflect.TypeOf(r.msgInstance).Elem()).Interface().(tdlib.TdMessage)
+	if err := json.Unmarshal(raw, msg); err != nil {
+		return
+	}
+	if !r.filterFunc(&msg) {
+		return
+	}
+	r.Lock()
+	if len(r.pending) < maxPendingUpdates {
+		r.pending = append(r.pending, msg)
+	}
+	r.Unlock()
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pump passes queued updates to `out` in order until receiver is closed, `out` is closed then
+func (r *tdlibReceiver) pump() {
+	defer close(r.out)
+	for {
+		r.Lock()
+		if len(r.pending) == 0 {
+			r.Unlock()
+			select {
+			case <-r.wake:
+				continue
+			case <-r.done:
+				return
+			}
+		}
+		msg := r.pending[0]
+		r.pending[0] = nil
+		r.pending = r.pending[1:]
+		r.Unlock()
+
+		select {
+		case r.out <- msg:
+		case <-r.done:
+			return
+		}
+	}
+}
+
+var _ TelegramBackend = (*TdlibBackend)(nil)
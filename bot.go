@@ -1,8 +1,9 @@
 package bottalker
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"regexp"
 	"sync"
 	"time"
 
@@ -11,26 +12,59 @@ import (
 
 // Bot is bot
 type Bot struct {
-	Label          string                  // friendly name
-	ChatID         int64                   // Telegram chat id
-	ChkInterval    time.Duration           // delay interval between checks
-	Commands       []BotCommandType        // bot commands to be sent by interval
-	Replies        chan<- *tdlib.TdMessage // channel to receive replies as Telegram message
-	TelegramClient *TelegramClient         // parent struct that holds Telegram client
-	ticker         *time.Ticker            // ticker is here to make it stop
+	Label          string                    // friendly name
+	ChatID         int64                     // Telegram chat id
+	ChkInterval    time.Duration             // delay interval between checks, may be zero if all active commands have `Schedule`
+	Commands       []BotCommandType          // bot commands to be sent by interval or by their own schedule
+	Replies        chan<- *Reply             // channel to receive bot replies: new and edited messages, callback answers
+	Results        chan<- *ReplyResult       // deprecated: extracted data is passed to `Replies` as `Reply.Fields`
+	TelegramClient *TelegramClient           // parent struct that holds Telegram client
+	CooldownRules  []*CooldownRule           // replies matching any of rules put bot on cooldown
+	RetryPolicy    *RetryPolicy              // failed triggers handling, `DefaultRetryPolicy` is used if not specified
+	Reactions      []*ReactionRule           // actions to perform on matching replies
+	reactionCh     chan *reactionReply       // replies queued for reactions, handled by bot goroutine
+	answerCh       chan *answerReply         // callback answers queued for message handler, so triggers don't wait for `Replies`
+	ticker         *time.Ticker              // ticker is here to make it stop, nil if `ChkInterval` is zero
+	cooldownUntil  time.Time                 // no commands will be triggered until then, use `SetCooldown` to change it
+	NotifyID       int64                     // notify telegram chat (contact, bot, group, whatever) on fatal errors
+	notifier       *TelegramClient           // client to send notifications, set before init so init errors are notified too
+	LogID          int64                     // telegram chat to send periodic reports to
+	RepInterval    time.Duration             // reporting interval, reports are disabled if zero
+	report         botReport                 // bot activity since last report
+	waiters        map[*replyWaiter]struct{} // triggers waiting for reply, see `BotCommandType.TriggerAndWait`
+	inFlight       int                       // triggers being made, see `startTrigger`
+	triggerSeq     uint64                    // finished triggers, lets waiters notice triggers made while waiting
+	ctx            context.Context           // bottalker run context, used to pass replies caused by triggers
+	paused         bool                      // no commands are triggered while paused, see `Pause`
+	wakeCh         chan struct{}             // wakes bot goroutine up on commands state change
+	runtime        *botRuntime               // goroutines and subscriptions, nil until bot is started by Bottalker
+	metrics        *metrics                  // Bottalker metrics, nil until bot is started by Bottalker
+	logger         Logger                    // Bottalker logger with bot label, see `getLogger`
+	store          Store                     // Bottalker store, nil until bot is started by Bottalker with `Store`
+	stateMu        sync.Mutex                // orders state saves, see `saveState`
 	sync.RWMutex
-	// TODO: those guys are not implemented yet
-	cooldown    time.Duration // cooldown if you need a break, use `SetCooldown` to increase it
-	NotifyID    int64         // notify telegram chat (contact, bot, group, whatever) on kind of event
-	LogID       int64         // same as `NotifyID` but idea is to send log or report
-	RepInterval time.Duration // reporting interval
 }
 
-func (b *Bot) initBot(tc *TelegramClient, errCh chan<- *BotError) {
-	log.Println("Initing bot:", b.Label)
+func (b *Bot) initBot(ctx context.Context, tc *TelegramClient, errCh chan<- *BotError) {
+	logger := b.getLogger()
+	logger.Info("Initing bot")
+	b.Lock()
+	b.notifier = tc
+	b.Unlock()
 	tc.getHistory(b.ChatID)
-	b.TelegramClient = tc
-	b.ticker = time.NewTicker(b.ChkInterval)
+	if b.ChkInterval > 0 {
+		b.ticker = time.NewTicker(b.ChkInterval)
+	}
+	states := b.loadState()
+	// state is saved once bot is initialized, e.g. with resolved payload message IDs
+	defer b.saveState()
+	// client is set the last, so commands can't be triggered until they are initialized
+	defer func() {
+		b.Lock()
+		defer b.Unlock()
+		b.TelegramClient = tc
+		b.ctx = ctx
+	}()
 
 	for _, bc := range b.Commands {
 		bc.setBot(b)
@@ -38,31 +72,35 @@ func (b *Bot) initBot(tc *TelegramClient, errCh chan<- *BotError) {
 		case *BotCommandPayload:
 			bcp, ok := bc.(*BotCommandPayload)
 			if !ok {
-				errCh <- &BotError{
+				b.sendError(ctx, errCh, &BotError{
 					Err:         fmt.Errorf("Unable to assert bot command as payload"),
 					ErrType:     BotErrFatal,
 					Bot:         b,
 					CommandType: bc,
-				}
+				})
 				continue
 			}
-			log.Printf("\t[%s] starting for: %s\n", b.Label, bcp.Data)
+			logger.Info("Starting payload command", "command", bcp.Data)
 
 			bcp.setPayload()
+			if cs := states[bcp]; bcp.MsgID == 0 && cs != nil && cs.MsgID != 0 {
+				bcp.MsgID = cs.MsgID
+				logger.Debug("Using saved message", "command", bcp.Data, "msg_id", bcp.MsgID)
+			}
 			if bcp.MsgID == 0 {
-				log.Println("\t\tbmsgID is not defined, trying to use latest message")
+				logger.Debug("Message ID is not defined, trying to use latest message", "command", bcp.Data)
 				bmsg, err := tc.getMsgByDate(b.ChatID, int32(time.Now().Unix()), false)
 				if err != nil {
-					errCh <- &BotError{
+					b.sendError(ctx, errCh, &BotError{
 						Err:         fmt.Errorf("Unable to get latest message: %v", err),
 						ErrType:     BotErrFatal,
 						Bot:         b,
 						CommandType: bcp,
-					}
+					})
 					continue
 				} else {
 					bcp.MsgID = bmsg.ID
-					log.Println("\t\tbmsgID = ", bcp.MsgID)
+					logger.Debug("Using latest message", "command", bcp.Data, "msg_id", bcp.MsgID)
 				}
 			}
 			if !bcp.Passive {
@@ -71,43 +109,90 @@ func (b *Bot) initBot(tc *TelegramClient, errCh chan<- *BotError) {
 		case *BotCommandChat:
 			bcc, ok := bc.(*BotCommandChat)
 			if !ok {
-				errCh <- &BotError{
+				b.sendError(ctx, errCh, &BotError{
 					Err:         fmt.Errorf("Unable to assert bot command as chat"),
 					ErrType:     BotErrFatal,
 					Bot:         b,
 					CommandType: bc,
-				}
+				})
 				continue
 			}
-			log.Printf("\t[%s] starting for: %s\n", b.Label, bcc.Data)
+			logger.Info("Starting chat command", "command", bcc.Data)
 
 			_, err := tc.getMsgByDate(b.ChatID, int32(time.Now().Unix()), false)
 			if err != nil {
-				errCh <- &BotError{
+				b.sendError(ctx, errCh, &BotError{
 					Err:         fmt.Errorf("Unable to get latest message: %v", err),
 					ErrType:     BotErrFatal,
 					Bot:         b,
 					CommandType: bcc,
-				}
+				})
 				continue
 			}
 			if !bcc.Passive {
 				bcc.start()
 			}
+		case *BotCommandButton:
+			bcb := bc.(*BotCommandButton)
+			logger.Info("Starting button command", "command", bcb.Data)
+
+			if err := bcb.setMatcher(bcb.Match); err != nil {
+				b.sendError(ctx, errCh, &BotError{
+					Err:         fmt.Errorf("Unable to compile caption regexp: %v", err),
+					ErrType:     BotErrFatal,
+					Bot:         b,
+					CommandType: bcb,
+				})
+				continue
+			}
+			if !bcb.Passive {
+				bcb.start()
+			}
+		case *BotCommandKeyboard:
+			bck := bc.(*BotCommandKeyboard)
+			logger.Info("Starting keyboard command", "command", bck.Data)
+
+			if err := bck.setMatcher(bck.Match); err != nil {
+				b.sendError(ctx, errCh, &BotError{
+					Err:         fmt.Errorf("Unable to compile caption regexp: %v", err),
+					ErrType:     BotErrFatal,
+					Bot:         b,
+					CommandType: bck,
+				})
+				continue
+			}
+			if !bck.Passive {
+				bck.start()
+			}
+		}
+		if cs := states[bc]; cs != nil {
+			bc.setState(cs)
 		}
 	}
 }
 
-// TODO: implement bot status report
-func (b *Bot) genReport(client *tdlib.Client, errCh chan<- BotError) {
-	log.Println("genReport called for:", b)
+// getLogger returns bot logger, default one is used until bot is started by Bottalker
+func (b *Bot) getLogger() Logger {
+	b.RLock()
+	defer b.RUnlock()
+	if b.logger == nil {
+		return defaultLogger.With("bot", b.Label)
+	}
+	return b.logger
 }
 
-// getCommands returns array of active bot commands
+// getTelegramClient returns parent client, nil until bot is initialized
+func (b *Bot) getTelegramClient() *TelegramClient {
+	b.RLock()
+	defer b.RUnlock()
+	return b.TelegramClient
+}
+
+// getCommands returns array of active bot commands triggered by interval, scheduled ones are not included
 func (b *Bot) getCommands() []BotCommandType {
 	bcts := make([]BotCommandType, 0)
 	for _, bct := range b.Commands {
-		if bct.isRunning() {
+		if bct.isRunning() && bct.getSchedule() == nil {
 			bcts = append(bcts, bct)
 		}
 	}
@@ -116,60 +201,154 @@ func (b *Bot) getCommands() []BotCommandType {
 
 // getAlive checks if bot has any active commands to perform
 func (b *Bot) getAlive() bool {
-	if len(b.getCommands()) > 0 {
-		return true
+	for _, bct := range b.Commands {
+		if bct.isRunning() {
+			return true
+		}
 	}
 	return false
 }
 
-// run runs ticker to trigger bot commands by interval
+// run runs ticker to trigger bot commands by interval and scheduler to trigger scheduled ones until `ctx` is canceled
 //
-// this doesnt looks like thread safe but as this the only one thread
-// which will interact with BotCommands, I hope it should be ok
-func (b *Bot) run(errCh chan<- *BotError) {
+// Ticker position and scheduler are touched from here only, but commands may also be triggered from other
+// goroutines, e.g. by control API or direct `Trigger` calls, so bot and command state is guarded by their locks
+func (b *Bot) run(ctx context.Context, errCh chan<- *BotError) {
+	var tickerC <-chan time.Time
+	if b.ticker != nil {
+		defer b.ticker.Stop()
+		tickerC = b.ticker.C
+	}
+	logger := b.getLogger()
+	sched := newScheduler()
+	defer sched.stop()
 	tickerPos := 0
 	for {
+		sched.plan(b.Commands, time.Now())
 		select {
-		case t := <-b.ticker.C:
-			log.Println("Checked on:", t)
-			/*
-				cd := b.GetCooldown()
-				if cd > 0 {
-					time.Sleep(cd)
-					b.SetCooldown(0)
+		case <-ctx.Done():
+			logger.Info("Stopped")
+			return
+		case <-b.wakeCh:
+			// commands state changed, they are planned again on the next iteration
+		case rr := <-b.reactionCh:
+			if b.IsPaused() {
+				logger.Debug("Paused, reaction skipped")
+				continue
+			}
+			b.react(ctx, errCh, rr)
+		case t := <-sched.timer.C:
+			for _, bct := range sched.due(t) {
+				if b.IsPaused() {
+					logger.Debug("Paused, scheduled trigger skipped", "command", bct.getData())
+					continue
+				}
+				// scheduled trigger is skipped on cooldown, the next one is already planned
+				if cd := b.GetCooldown(); cd > 0 {
+					logger.Info("Cooling down, scheduled trigger skipped", "command", bct.getData(), "cooldown", cd)
+					continue
 				}
-			*/
+				logger.Debug("Triggering scheduled command", "command", bct.getData())
+				if _, bErr := b.trigger(ctx, bct, errCh); bErr != nil {
+					b.sendError(ctx, errCh, bErr)
+				}
+			}
+		case t := <-tickerC:
+			logger.Debug("Checked", "tick", t)
+			if b.IsPaused() {
+				continue
+			}
+			// skipping ticks keeps `tickerPos`, so cycle continues after cooldown
+			if cd := b.GetCooldown(); cd > 0 {
+				logger.Info("Cooling down", "cooldown", cd)
+				continue
+			}
 			bc := b.getCommands()
+			if len(bc) == 0 {
+				// nothing to trigger, all commands are passive or stopped
+				continue
+			}
+			// commands may stop between ticks, so position is wrapped against the current list
 			if tickerPos >= len(bc) {
 				tickerPos = 0
-				for _, botCommands := range b.getCommands() {
-					botCommands.stop()
-				}
 			}
-			log.Printf("%d > quering: %v", tickerPos, bc[tickerPos])
-			_, bErr := bc[tickerPos].Trigger()
+			logger.Debug("Triggering command", "position", tickerPos, "command", bc[tickerPos].getData())
+			_, bErr := b.trigger(ctx, bc[tickerPos], errCh)
 			tickerPos++
 			if bErr != nil {
-				errCh <- bErr
+				b.sendError(ctx, errCh, bErr)
 			}
 		}
 	}
 }
 
-// TODO: Implement cooldown login
-
-// SetCooldown adds timeout cooldown before next command trigger
+// SetCooldown pauses command triggers for `d`, zero or negative `d` resumes bot
 func (b *Bot) SetCooldown(d time.Duration) {
 	b.Lock()
-	defer b.Unlock()
-	b.cooldown = d
+	if d <= 0 {
+		b.cooldownUntil = time.Time{}
+	} else {
+		b.cooldownUntil = time.Now().Add(d)
+	}
+	b.Unlock()
+	b.saveState()
 }
 
-// GetCooldown adds timeout cooldown before next command trigger
+// GetCooldown returns remaining cooldown, zero if bot is active
 func (b *Bot) GetCooldown() time.Duration {
-	b.Lock()
-	defer b.Unlock()
-	return b.cooldown
+	b.RLock()
+	defer b.RUnlock()
+	if b.cooldownUntil.IsZero() {
+		return 0
+	}
+	if d := time.Until(b.cooldownUntil); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// BotStatus is bot state snapshot
+type BotStatus struct {
+	Label         string          // bot friendly name
+	ChatID        int64           // Telegram chat id
+	Paused        bool            // bot is paused, see `Bot.Pause`
+	Cooldown      time.Duration   // remaining cooldown, zero if bot is active
+	CooldownUntil time.Time       // end of cooldown, zero if bot is active
+	Commands      []CommandStatus // bot commands state
+}
+
+// CommandStatus is bot command state snapshot
+type CommandStatus struct {
+	Data       string            // command data
+	Running    bool              // command is queued for trigger
+	Triggers   int               // finished triggers since command start, see `BotCommand.Repeat`
+	LastReply  string            // text of the last reply received after command trigger
+	LastResult map[string]string // data extracted from the last reply, see `BotCommand.Extract`
+}
+
+// Status returns current bot state
+func (b *Bot) Status() *BotStatus {
+	bs := &BotStatus{
+		Label:    b.Label,
+		ChatID:   b.ChatID,
+		Paused:   b.IsPaused(),
+		Cooldown: b.GetCooldown(),
+	}
+	if bs.Cooldown > 0 {
+		b.RLock()
+		bs.CooldownUntil = b.cooldownUntil
+		b.RUnlock()
+	}
+	for _, bct := range b.Commands {
+		bs.Commands = append(bs.Commands, CommandStatus{
+			Data:       string(bct.getData()),
+			Running:    bct.isRunning(),
+			Triggers:   bct.getTriggers(),
+			LastReply:  bct.getReport().getLastReply(),
+			LastResult: bct.getReport().getLastResult(),
+		})
+	}
+	return bs
 }
 
 // BotError contains everything about error caused to bot
@@ -190,6 +369,19 @@ const (
 	BotErrFatal                         // bot crashes
 )
 
+// String returns error type name
+func (et BotErrorTypeEnum) String() string {
+	switch et {
+	case BotErrWarn:
+		return "warn"
+	case BotErrError:
+		return "error"
+	case BotErrFatal:
+		return "fatal"
+	}
+	return fmt.Sprintf("unknown(%d)", int(et))
+}
+
 func (bErr *BotError) Error() (errMsg string) {
 	if bErr.Bot != nil {
 		errMsg += fmt.Sprintf("%s > ", bErr.Bot.Label)
@@ -207,11 +399,23 @@ func (bErr *BotError) Error() (errMsg string) {
 type BotCommandType interface {
 	start() // set isRunning
 	stop()  // unset isRunning
+	Start() // queue command for triggers at runtime
+	Stop()  // remove command from queue at runtime
 
-	setBot(b *Bot)                        // define parent
-	Trigger() (*tdlib.Message, *BotError) // trigerring command, the way to interract with passive commands but can used with any
-	isRunning() bool                      // return running state
-	getData() []byte                      // get command data
+	getBot() *Bot                                                                  // get parent
+	setBot(b *Bot)                                                                 // define parent
+	Trigger() (*tdlib.Message, *BotError)                                          // trigerring command, the way to interract with passive commands but can used with any
+	TriggerAndWait(ctx context.Context, timeout time.Duration) (*Reply, *BotError) // trigerring command and returning bot's reply, works while bottalker is running
+	isRunning() bool                                                               // return running state
+	getData() []byte                                                               // get command data
+	getSchedule() Schedule                                                         // get command own schedule
+	countTrigger() bool                                                            // count finished trigger, true if command is done repeating
+	getState() *CommandState                                                       // get command state to persist
+	setState(cs *CommandState)                                                     // restore persisted command state
+	matchUntil(text string) bool                                                   // stop command if reply matches its repeat condition
+	getTriggers() int                                                              // get finished triggers since start
+	getExtractRules() []*ExtractRule                                               // get rules to extract data from replies
+	getReport() *commandReport                                                     // get command activity since last report
 }
 
 // BotCommandPayload interacting with inline keyboard
@@ -228,13 +432,20 @@ type BotCommandChat struct {
 
 // BotCommand is message
 type BotCommand struct {
-	Data    []byte // Data will be send as payload to bot
-	Passive bool   // Set passive for bot commands that will be triggered manually by `BotCommand.Trigger()`
-	running bool   // can be stopper and started by `BotCommand.stop()` and `BotCommand.start()`
-	bot     *Bot   // parent struct
+	Data        []byte         // Data will be send as payload to bot
+	Passive     bool           // Set passive for bot commands that will be triggered manually by `BotCommand.Trigger()`
+	Schedule    Schedule       // own trigger schedule, see `Every`, `DailyAt` and `ParseCron`; command is triggered by bot `ChkInterval` in turn if nil
+	Repeat      RepeatModeEnum // how long command is repeated, forever by default
+	RepeatTimes int            // RepeatTimes only; number of triggers before command stops
+	RepeatUntil *regexp.Regexp // RepeatUntil only; command stops when reply to it matches
+	Extract     []*ExtractRule // rules to extract data from replies, see `Reply.Fields`
+	running     bool           // can be stopper and started by `BotCommand.stop()` and `BotCommand.start()`
+	triggers    int            // finished triggers since start, see `BotCommand.Repeat`
+	bot         *Bot           // parent struct
+	report      commandReport  // command activity since last report
+
+	matcher *captionMatcher // button and keyboard only; built from `Data` by `BotCommand.setMatcher()`
 	sync.RWMutex
-	// TODO: Part of reporting functionality
-	reportData string // Will contain report data
 }
 
 // start adding command to queue
@@ -242,6 +453,7 @@ func (bc *BotCommand) start() {
 	bc.Lock()
 	defer bc.Unlock()
 	bc.running = true
+	bc.triggers = 0
 }
 
 // stop removing command from queue
@@ -251,16 +463,72 @@ func (bc *BotCommand) stop() {
 	bc.running = false
 }
 
+// Start adds command to queue, so it's triggered by interval or by its schedule again; repetitions are counted from scratch
+func (bc *BotCommand) Start() {
+	bc.start()
+	if b := bc.getBot(); b != nil {
+		b.saveState()
+		b.wake()
+	}
+}
+
+// Stop removes command from queue, it still can be triggered manually
+func (bc *BotCommand) Stop() {
+	bc.stop()
+	if b := bc.getBot(); b != nil {
+		b.saveState()
+		b.wake()
+	}
+}
+
 // getData returns data which will be sent to Telegram message's InlineKeyboardButton
 func (bc *BotCommand) getData() []byte {
 	return bc.Data
 }
 
+// getSchedule returns command own schedule, nil if it's triggered by bot interval
+func (bc *BotCommand) getSchedule() Schedule {
+	return bc.Schedule
+}
+
+// getExtractRules returns rules to extract data from replies
+func (bc *BotCommand) getExtractRules() []*ExtractRule {
+	return bc.Extract
+}
+
+// getReport returns command activity since last report
+func (bc *BotCommand) getReport() *commandReport {
+	return &bc.report
+}
+
 // setBot links BotCommand with Bot
 func (bc *BotCommand) setBot(b *Bot) {
+	bc.Lock()
+	defer bc.Unlock()
 	bc.bot = b
 }
 
+// getBot returns parent Bot, nil until bot is initialized
+func (bc *BotCommand) getBot() *Bot {
+	bc.RLock()
+	defer bc.RUnlock()
+	return bc.bot
+}
+
+// getInitializedBot returns parent Bot, error is returned until bot is initialized
+func (bc *BotCommand) getInitializedBot(bct BotCommandType) (*Bot, *BotError) {
+	b := bc.getBot()
+	if b == nil || b.getTelegramClient() == nil {
+		return nil, &BotError{
+			Err:         fmt.Errorf("Bot is not initialized"),
+			ErrType:     BotErrFatal,
+			Bot:         b,
+			CommandType: bct,
+		}
+	}
+	return b, nil
+}
+
 // isRunning returns current run state
 func (bc *BotCommand) isRunning() bool {
 	bc.Lock()
@@ -277,53 +545,76 @@ func (bcp *BotCommandPayload) setPayload() {
 
 // Trigger interacting with bot
 func (bcp *BotCommandPayload) Trigger() (*tdlib.Message, *BotError) {
-	_, err := bcp.bot.TelegramClient.client.GetCallbackQueryAnswer(bcp.bot.ChatID, bcp.MsgID, bcp.payloadData)
+	m, _, bErr := bcp.TriggerWithAnswer()
+	return m, bErr
+}
+
+// TriggerWithAnswer interacting with bot and returning its callback answer as well
+func (bcp *BotCommandPayload) TriggerWithAnswer() (m *tdlib.Message, answer *tdlib.CallbackQueryAnswer, bErr *BotError) {
+	b, bErr := bcp.getInitializedBot(bcp)
+	if bErr != nil {
+		return nil, nil, bErr
+	}
+	start := b.startTrigger()
+	defer func() { b.observeTrigger(bcp, start, bErr) }()
+	answer, err := b.TelegramClient.Backend.GetCallbackQueryAnswer(b.ChatID, bcp.MsgID, bcp.payloadData)
 	if err != nil {
 		switch err.Error() {
 		case "timeout":
-			return nil, &BotError{
+			return nil, nil, &BotError{
 				Err:         fmt.Errorf("GetCallbackQueryAnswer [%v] failed: %s", bcp.Data, err),
 				ErrType:     BotErrWarn,
-				Bot:         bcp.bot,
+				Bot:         b,
 				CommandType: bcp,
 			}
 		default:
-			return nil, &BotError{
+			return nil, nil, &BotError{
 				Err:         fmt.Errorf("GetCallbackQueryAnswer [%v] failed: %s", bcp.Data, err),
 				ErrType:     BotErrError,
-				Bot:         bcp.bot,
+				Bot:         b,
 				CommandType: bcp,
 			}
 		}
 	}
-	m, err := bcp.bot.TelegramClient.client.GetMessage(bcp.bot.ChatID, bcp.MsgID)
+	m, err = b.TelegramClient.Backend.GetMessage(b.ChatID, bcp.MsgID)
 	if err != nil {
-		return nil, &BotError{
+		return nil, nil, &BotError{
 			Err:         fmt.Errorf("GetMessage [%d] failed: %s", bcp.MsgID, err),
 			ErrType:     BotErrError,
-			Bot:         bcp.bot,
+			Bot:         b,
 			CommandType: bcp,
 		}
 	}
-	return m, nil
+	b.handleAnswer(bcp, m, answer, start)
+	return m, answer, nil
 }
 
 // Trigger performin a query
-func (bcc *BotCommandChat) Trigger() (*tdlib.Message, *BotError) {
-	m, err := bcc.bot.TelegramClient.client.SendMessage(bcc.bot.ChatID, int64(0), int64(0), tdlib.NewMessageSendOptions(false, false, nil), nil,
-		tdlib.NewInputMessageText(
-			tdlib.NewFormattedText(string(bcc.Data), nil),
-			true,
-			true,
-		),
-	)
+func (bcc *BotCommandChat) Trigger() (m *tdlib.Message, bErr *BotError) {
+	b, bErr := bcc.getInitializedBot(bcc)
+	if bErr != nil {
+		return nil, bErr
+	}
+	start := b.startTrigger()
+	defer func() { b.observeTrigger(bcc, start, bErr) }()
+	m, err := b.TelegramClient.sendText(b.ChatID, string(bcc.Data))
 	if err != nil {
 		return nil, &BotError{
 			Err:         fmt.Errorf("SendMessage [%s] failed: %s", bcc.Data, err),
 			ErrType:     BotErrFatal,
-			Bot:         bcc.bot,
+			Bot:         b,
 			CommandType: bcc,
 		}
 	}
 	return m, nil
 }
+
+// TriggerAndWait interacting with bot and waiting for reply, which is either new bot message or keyboard message edit
+func (bcp *BotCommandPayload) TriggerAndWait(ctx context.Context, timeout time.Duration) (*Reply, *BotError) {
+	return bcp.getBot().triggerAndWait(ctx, bcp, timeout)
+}
+
+// TriggerAndWait performing a query and waiting for bot message replying to it
+func (bcc *BotCommandChat) TriggerAndWait(ctx context.Context, timeout time.Duration) (*Reply, *BotError) {
+	return bcc.getBot().triggerAndWait(ctx, bcc, timeout)
+}
@@ -1,15 +1,15 @@
 package bottalker
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"math"
+	"net"
+	"net/http"
 	"os"
-	"os/signal"
 	"path/filepath"
 	"strings"
 	"sync"
-	"syscall"
 	"time"
 
 	"github.com/Arman92/go-tdlib"
@@ -19,172 +19,408 @@ import (
 // Bottalker is the main struct of bottalker app
 type Bottalker struct {
 	TelegramClient   *TelegramClient // telegram client settings struct; Can be generated via wizard, leave blank for wizard prompt
-	Bots             []*Bot          // array of bot struct for telegram message; Can be generated via wizard, leave blank for wizard prompt
+	Bots             []*Bot          // array of bot struct for telegram message; Can be generated via wizard, leave blank for wizard prompt; use `AddBot` and `RemoveBot` while running
 	TelegramLog      *string         // full path to tdlib log; Example: `./logs/tdlib.log`, default is stdout
 	TelegralLogLevel int             // verbosity level for tdlib, default is 5; Check `tdlib/SetLogVerbosityLevel` for more info
 	TalkerLog        *string         // full path to bottalker-go log; Example `./logs/talker.log`, default is stdout
 	ErrChan          chan *BotError  // errors channel; Specify and handle *BotError channel if you want to. `bottalker-go/defaultErrorHandler` will be used if not specified
-	wg               *sync.WaitGroup // holds thread until bots stop
+	Logger           Logger          // structured logger; text logger writing to `TalkerLog` or stderr is used if not specified
+	TalkerLogFormat  string          // format of default logger: `text` (default) or `json`
+	TalkerLogLevel   string          // level of default logger: `debug`, `info` (default), `warn` or `error`
+	ControlAddr      string          // address to serve HTTP control API on, like `127.0.0.1:8080`; API is disabled if empty, see `ControlHandler`
+	ControlToken     string          // bearer token required by control API; required to serve it on non-loopback `ControlAddr`
+	Store            Store           // persistent bot state, see FileStore; state is lost on restart if not specified
+	MetricsAddr      string          // address to serve Prometheus metrics on, like `:9090`; metrics are collected but not served if empty, see `MetricsHandler`
+	metrics          *metrics        // collected metrics, see `getMetrics`
+	logger           Logger          // logger with client ID, set while running, see `getLogger`
+	ctx              context.Context // run context, bots added by `AddBot` are started with it
+	sync.RWMutex
 }
 
-// Run is running bottalker instance
-func (bt *Bottalker) Run() {
-	if bt.TelegralLogLevel > 0 {
-		tdlib.SetLogVerbosityLevel(bt.TelegralLogLevel)
+// Run is running bottalker instance until `ctx` is canceled
+//
+// On cancellation bots are stopped, in-flight triggers are awaited and Telegram client is destroyed,
+// nil is returned after such clean shutdown. Signal handling is up to the caller.
+func (bt *Bottalker) Run(ctx context.Context) error {
+	closeLog, err := bt.initLogger()
+	if err != nil {
+		return err
 	}
+	defer closeLog()
 
-	if bt.TelegramLog != nil {
-		logPath := "./logs/telegram.log"
-		if *bt.TelegramLog != "" {
-			logPath = *bt.TelegramLog
-		}
-		createDir(logPath)
-		tdlib.SetFilePath(logPath)
+	if err := bt.checkControlAddr(); err != nil {
+		return err
 	}
+	if err := bt.initBackend(); err != nil {
+		return fmt.Errorf("Unable to build config: %v", err)
+	}
+	defer bt.TelegramClient.Backend.DestroyInstance()
+	defer bt.watchConnection().Close()
 
-	if bt.TalkerLog != nil {
-		logPath := "./logs/bottalker.log"
-		if *bt.TalkerLog != "" {
-			logPath = *bt.TalkerLog
-		}
-		f, err := os.OpenFile(logPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-		if err != nil {
-			log.Panicf("Error opening file: %v", err)
-		}
-		defer f.Close()
-		log.SetOutput(f)
-	}
-
-	// This is working client config
-	// Those fields will be merged with your config
-	clientConfig := tdlib.Config{
-		APIID:                  "859145",                           // better (for me) if you use your own `APIID`, you can get it on: https://my.telegram.org
-		APIHash:                "0515b7132ab667dc75a8eb71485ec27c", // better (for me) if you use your own `APIHash`, you can get it on: https://my.telegram.org
-		SystemLanguageCode:     "en",
-		DeviceModel:            "Desktop",
-		SystemVersion:          "0.0.1",
-		ApplicationVersion:     "0.0.1",
-		UseFileDatabase:        true,
-		UseMessageDatabase:     false,
-		UseChatInfoDatabase:    false,
-		UseTestDataCenter:      false,
-		DatabaseDirectory:      fmt.Sprintf("./clients/%s/tdlib-db", bt.TelegramClient.ID),
-		FileDirectory:          fmt.Sprintf("./clients/%s/tdlib-files", bt.TelegramClient.ID),
-		IgnoreFileNames:        false,
-		EnableStorageOptimizer: true,
-	}
-
-	// Merging your config with default
-	if err := mergo.Merge(&clientConfig, bt.TelegramClient.Config); err != nil {
-		log.Panic("Unable to build config: ", err)
-	}
-
-	bt.TelegramClient.client = tdlib.NewClient(clientConfig)
-
-	// Handle Ctrl+C, Gracefully exit and shutdown tdlib
-	// Actaully I'm not sure how graceful it is
-	ch := make(chan os.Signal, 2)
-	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-ch
-		bt.TelegramClient.client.DestroyInstance()
-		os.Exit(0)
-	}()
-
-	err := bt.connect()
+	err = bt.connect()
 	if err != nil {
-		log.Println("Unable to start app:", err)
+		return fmt.Errorf("Unable to start app: %v", err)
 	}
 
 	// We always need to get chat list first, even if we're accessing conversation via ID
 	// It's telegram logic
-	err = bt.TelegramClient.initChatList()
+	err = bt.TelegramClient.initChatList(bt.getLogger())
 	if err != nil {
-		log.Println("Unable to start app:", err)
-		return
+		return fmt.Errorf("Unable to start app: %v", err)
 	}
 
 	// Hadling errors in your chan if you have it
 	if bt.ErrChan == nil {
-		bt.ErrChan = make(chan *BotError)
-		go bt.defaultErrorHandler()
+		bt.ErrChan = make(chan *BotError, 100)
+		go bt.defaultErrorHandler(ctx)
+	}
+
+	var servers []*httpServer
+	for _, api := range []struct {
+		name    string
+		addr    string
+		handler func() http.Handler
+	}{
+		{"control API", bt.ControlAddr, bt.ControlHandler},
+		{"metrics", bt.MetricsAddr, bt.MetricsHandler},
+	} {
+		if api.addr == "" {
+			continue
+		}
+		srv, err := bt.serveHTTP(api.name, api.addr, api.handler())
+		if err != nil {
+			shutdownHTTP(servers)
+			return err
+		}
+		servers = append(servers, srv)
+	}
+
+	bt.startWorkers(ctx)
+	<-ctx.Done()
+	// requests may wait for replies, they are canceled once bots stop
+	shutdownHTTP(servers)
+	bt.shutdown()
+	return nil
+}
+
+// httpServer is HTTP server started by `serveHTTP`
+type httpServer struct {
+	*http.Server
+	done chan struct{} // closed once server stops serving and its listener is closed
+}
+
+// shutdownHTTP shuts servers down waiting a bit for in-flight requests
+func shutdownHTTP(servers []*httpServer) {
+	for _, srv := range servers {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		srv.Shutdown(shutdownCtx)
+		cancel()
+		<-srv.done
+	}
+}
+
+// serveHTTP serves `handler` on `addr` until returned server is shut down
+func (bt *Bottalker) serveHTTP(name string, addr string, handler http.Handler) (*httpServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to start %s: %v", name, err)
+	}
+	srv := &httpServer{Server: &http.Server{Handler: handler}, done: make(chan struct{})}
+	go func() {
+		defer close(srv.done)
+		logger := bt.getLogger().With("server", name)
+		logger.Info("Serving HTTP", "addr", ln.Addr())
+		// listener is closed by Serve even if server is shut down before it starts serving
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.Error("HTTP server stopped", "error", err)
+		}
+	}()
+	return srv, nil
+}
+
+// shutdown waits for bots to stop and unsubscribes message handlers
+func (bt *Bottalker) shutdown() {
+	bt.getLogger().Info("Shutting down")
+	bt.Lock()
+	bt.ctx = nil
+	bots := append([]*Bot(nil), bt.Bots...)
+	bt.Unlock()
+	for _, b := range bots {
+		b.stopRuntime()
+	}
+	bt.closeStore()
+}
+
+// initBackend starts tdlib client unless backend is already defined
+func (bt *Bottalker) initBackend() error {
+	// Backend may be predefined, e.g. for testing purposes
+	// otherwise we're starting tdlib client
+	if bt.TelegramClient.Backend == nil {
+		if bt.TelegralLogLevel > 0 {
+			tdlib.SetLogVerbosityLevel(bt.TelegralLogLevel)
+		}
+
+		if bt.TelegramLog != nil {
+			logPath := "./logs/telegram.log"
+			if *bt.TelegramLog != "" {
+				logPath = *bt.TelegramLog
+			}
+			if err := createDir(logPath); err != nil {
+				bt.getLogger().Warn("Unable to create Telegram log dir", "path", logPath, "error", err)
+			}
+			tdlib.SetFilePath(logPath)
+		}
+
+		// This is working client config
+		// Those fields will be merged with your config
+		clientConfig := tdlib.Config{
+			APIID:                  "859145",                           // better (for me) if you use your own `APIID`, you can get it on: https://my.telegram.org
+			APIHash:                "0515b7132ab667dc75a8eb71485ec27c", // better (for me) if you use your own `APIHash`, you can get it on: https://my.telegram.org
+			SystemLanguageCode:     "en",
+			DeviceModel:            "Desktop",
+			SystemVersion:          "0.0.1",
+			ApplicationVersion:     "0.0.1",
+			UseFileDatabase:        true,
+			UseMessageDatabase:     false,
+			UseChatInfoDatabase:    false,
+			UseTestDataCenter:      false,
+			DatabaseDirectory:      fmt.Sprintf("./clients/%s/tdlib-db", bt.TelegramClient.ID),
+			FileDirectory:          fmt.Sprintf("./clients/%s/tdlib-files", bt.TelegramClient.ID),
+			IgnoreFileNames:        false,
+			EnableStorageOptimizer: true,
+		}
+
+		// Merging your config with default
+		if bt.TelegramClient.Config != nil {
+			if err := mergo.Merge(&clientConfig, bt.TelegramClient.Config); err != nil {
+				return err
+			}
+		}
+
+		bt.TelegramClient.Backend = NewTdlibBackend(clientConfig)
+	}
+	return nil
+}
+
+// Connect starts Telegram client, authorizes it and loads chat list
+// Authorization wizard will be proposed if client is not authorized yet
+func (bt *Bottalker) Connect() error {
+	if err := bt.initBackend(); err != nil {
+		return fmt.Errorf("Unable to build config: %v", err)
+	}
+	if err := bt.connect(); err != nil {
+		return err
 	}
+	return bt.TelegramClient.initChatList(bt.getLogger())
+}
 
-	bt.wg = &sync.WaitGroup{}
+// Close destroys Telegram client started by `Connect` or `Login`, `Run` does it on its own
+func (bt *Bottalker) Close() {
+	if bt.TelegramClient.Backend != nil {
+		bt.TelegramClient.Backend.DestroyInstance()
+	}
+}
 
-	bt.startWorkers()
+// Login starts Telegram client and runs authorization wizard
+func (bt *Bottalker) Login() error {
+	if err := bt.initBackend(); err != nil {
+		return fmt.Errorf("Unable to build config: %v", err)
+	}
+	bt.addProxies()
+	return bt.wizard(WizardClient)
 }
 
 // defaultErrorHandler log errors received in error chan
-func (bt *Bottalker) defaultErrorHandler() {
-	log.Printf("%s > Starting defaultErrorHandler", bt.TelegramClient.ID)
-	for bErr := range bt.ErrChan {
+func (bt *Bottalker) defaultErrorHandler(ctx context.Context) {
+	logger := bt.getLogger()
+	logger.Debug("Starting defaultErrorHandler")
+	for {
+		var bErr *BotError
+		select {
+		case bErr = <-bt.ErrChan:
+		case <-ctx.Done():
+			return
+		}
+
+		// retries and delays are up to bot `RetryPolicy`, we're just logging here
+		keyvals := []interface{}{"type", bErr.ErrType, "error", bErr.Err}
+		if bErr.Bot != nil {
+			keyvals = append(keyvals, "bot", bErr.Bot.Label)
+		}
+		if bErr.CommandType != nil {
+			keyvals = append(keyvals, "command", bErr.CommandType.getData())
+		}
 		switch bErr.ErrType {
 		case BotErrFatal:
-			log.Printf("Terminated: %s", bErr)
-			time.Sleep(30 * time.Second)
+			logger.Error("Terminated", keyvals...)
 		case BotErrWarn:
-			log.Printf("Warned with: %s", bErr)
-		case BotErrError:
-			log.Printf("Error: %s", bErr)
-			time.Sleep(30 * time.Second)
+			logger.Warn("Warned", keyvals...)
 		default:
-			log.Printf("Unknown error [%v]: %s", bErr.ErrType, bErr)
+			logger.Error("Errored", keyvals...)
 		}
 	}
 }
 
-// initMessageHandler filter messages and process them to `replies` channel
+// initMessageHandler filter bot messages and process them to `replies` channel
 // I had plans to make this customizable but those settings should fit most cases
-func (bt *Bottalker) initMessageHandler() {
-	log.Printf("%s > Starting messageHandler", bt.TelegramClient.ID)
-	for _, b := range bt.Bots {
-		if b.Replies == nil {
-			continue
+func (bt *Bottalker) initMessageHandler(ctx context.Context, b *Bot, rt *botRuntime) {
+	b.getLogger().Debug("Starting messageHandler")
+	chatID := b.ChatID
+	eventFilter := func(msg *tdlib.TdMessage) bool {
+		switch (*msg).(type) {
+		case *tdlib.UpdateMessageContent:
+			if (*msg).(*tdlib.UpdateMessageContent).ChatID == chatID {
+				return true
+			}
+		case *tdlib.UpdateMessageEdited:
+			if (*msg).(*tdlib.UpdateMessageEdited).ChatID == chatID {
+				return true
+			}
+		case *tdlib.UpdateChatLastMessage:
+			if (*msg).(*tdlib.UpdateChatLastMessage).ChatID == chatID {
+				return true
+			}
+		case *tdlib.UpdateNewMessage:
+			if (*msg).(*tdlib.UpdateNewMessage).Message.ChatID == chatID {
+				return true
+			}
+		case *tdlib.UpdateMessageSendSucceeded:
+			if (*msg).(*tdlib.UpdateMessageSendSucceeded).Message.ChatID == chatID {
+				return true
+			}
 		}
-		eventFilter := func(msg *tdlib.TdMessage) bool {
-			switch (*msg).(type) {
-			case *tdlib.UpdateMessageContent:
-				if (*msg).(*tdlib.UpdateMessageContent).ChatID == b.ChatID {
-					return true
-				}
-			case *tdlib.UpdateMessageEdited:
-				if (*msg).(*tdlib.UpdateMessageEdited).ChatID == b.ChatID {
-					return true
-				}
-			case *tdlib.UpdateChatLastMessage:
-				if (*msg).(*tdlib.UpdateChatLastMessage).ChatID == b.ChatID {
-					return true
-				}
+		return false
+	}
+	// This 3 messages happens on InlineKeyboardButton trigger
+	msgInstances := []tdlib.TdMessage{
+		&tdlib.UpdateMessageContent{},
+		&tdlib.UpdateMessageEdited{},
+		&tdlib.UpdateChatLastMessage{},
+	}
+	for _, msgInstance := range msgInstances {
+		receiver := bt.TelegramClient.Backend.AddEventReceiver(msgInstance, eventFilter, 100)
+		rt.receivers = append(rt.receivers, receiver)
+		rt.handlersWg.Add(1)
+		go func(receiver *EventReceiver) {
+			defer rt.handlersWg.Done()
+			for newMsg := range receiver.Chan {
+				msg := newMsg // `newMsg` is reused by range, don't send its address
+				b.handleReply(ctx, &msg, nil)
+			}
+		}(receiver)
+	}
+
+	// callback answers aren't updates, they are queued by triggers
+	b.RLock()
+	answerCh := b.answerCh
+	b.RUnlock()
+	rt.handlersWg.Add(1)
+	go func() {
+		defer rt.handlersWg.Done()
+		for {
+			select {
+			case ar := <-answerCh:
+				msg := tdlib.TdMessage(ar.answer)
+				b.handleReply(ctx, &msg, ar.msg)
+			case <-ctx.Done():
+				return
 			}
-			return false
 		}
-		// This 3 messages happens on InlineKeyboardButton trigger
-		msgInstances := []tdlib.TdMessage{
-			&tdlib.UpdateMessageContent{},
-			&tdlib.UpdateMessageEdited{},
-			&tdlib.UpdateChatLastMessage{},
+	}()
+
+	// Those are needed to match replies with triggers only, they aren't passed to `replies`
+	waitInstances := []tdlib.TdMessage{
+		&tdlib.UpdateNewMessage{},
+		&tdlib.UpdateMessageSendSucceeded{},
+	}
+	for _, msgInstance := range waitInstances {
+		receiver := bt.TelegramClient.Backend.AddEventReceiver(msgInstance, eventFilter, 100)
+		rt.receivers = append(rt.receivers, receiver)
+		rt.handlersWg.Add(1)
+		go func(receiver *EventReceiver) {
+			defer rt.handlersWg.Done()
+			for newMsg := range receiver.Chan {
+				msg := newMsg
+				b.offerReply(&msg)
+				b.queueReaction(&msg)
+			}
+		}(receiver)
+	}
+}
+
+// handleReply processes bot update and passes it to `Bot.Replies` as Reply unless `ctx` is canceled,
+// `m` is message update is about if it's known already
+func (b *Bot) handleReply(ctx context.Context, msg *tdlib.TdMessage, m *tdlib.Message) {
+	b.offerReply(msg)
+	b.queueReaction(msg)
+	var bct BotCommandType
+	var fields map[string]string
+	if text, ok := getReplyText(msg); ok {
+		bct = b.trackReply(text)
+		b.getMetrics().observeReply(b, (*msg).MessageType(), bct)
+		b.trackRepeat(bct, text)
+		b.applyCooldownRules(text)
+		fields = b.extractResult(ctx, bct, text)
+	} else {
+		b.report.Lock()
+		bct = b.report.lastCommand
+		b.report.Unlock()
+	}
+	if b.Replies == nil && b.getStore() == nil {
+		return
+	}
+	reply := b.newReply(*msg, m, bct)
+	if reply == nil {
+		return
+	}
+	reply.Fields = fields
+	b.logReply(reply)
+	b.saveState()
+	if b.Replies != nil {
+		select {
+		case b.Replies <- reply:
+		case <-ctx.Done():
 		}
-		for _, msgInstance := range msgInstances {
-			go func(b *Bot, msgInstance tdlib.TdMessage) {
-				for newMsg := range bt.TelegramClient.client.AddEventReceiver(msgInstance, eventFilter, 100).Chan {
-					b.Replies <- &newMsg
-				}
-			}(b, msgInstance)
+	}
+}
+
+// addProxies passes configured proxies to Telegram client
+func (bt *Bottalker) addProxies() {
+	for _, proxy := range bt.TelegramClient.Proxies {
+		if _, err := bt.TelegramClient.Backend.AddProxy(proxy.Server, proxy.Port, proxy.Enable, proxy.TypeParam); err != nil {
+			bt.getLogger().Warn("Unable to add proxy", "server", proxy.Server, "port", proxy.Port, "error", err)
 		}
 	}
 }
 
+// watchConnection logs Telegram client connection state changes and updates metrics until receiver is closed
+func (bt *Bottalker) watchConnection() *EventReceiver {
+	receiver := bt.TelegramClient.Backend.AddEventReceiver(&tdlib.UpdateConnectionState{}, func(*tdlib.TdMessage) bool {
+		return true
+	}, 10)
+	go func() {
+		for msg := range receiver.Chan {
+			update, ok := msg.(*tdlib.UpdateConnectionState)
+			if !ok || update.State == nil {
+				continue
+			}
+			state := string(update.State.GetConnectionStateEnum())
+			bt.getLogger().Info("Connection state", "state", state)
+			bt.getMetrics().setConnState(state)
+		}
+	}()
+	return receiver
+}
+
 // connect initializes Telegram client session
 func (bt *Bottalker) connect() error {
-	for _, proxy := range bt.TelegramClient.Proxies {
-		bt.TelegramClient.client.AddProxy(proxy.Server, proxy.Port, proxy.Enable, proxy.TypeParam)
-	}
+	bt.addProxies()
 
 AUTHLOOP:
 	for {
-		currentState, _ := bt.TelegramClient.client.Authorize()
-		log.Println(bt.TelegramClient.ID, "> Authorization State:", currentState.GetAuthorizationStateEnum())
+		currentState, _ := bt.TelegramClient.Backend.Authorize()
+		bt.getLogger().Info("Authorization state", "state", currentState.GetAuthorizationStateEnum())
+		bt.getMetrics().setAuthState(string(currentState.GetAuthorizationStateEnum()))
 		switch currentState.GetAuthorizationStateEnum() {
 		case tdlib.AuthorizationStateWaitEncryptionKeyType:
 			continue
@@ -213,29 +449,21 @@ AUTHLOOP:
 	}
 }
 
-// startWorkers starting workers
-func (bt *Bottalker) startWorkers() {
-	log.Printf("%s > Starting startWorkers", bt.TelegramClient.ID)
-
-	// initializing message handler first
-	bt.initMessageHandler()
+// startWorkers starting workers, they will be running until `ctx` is canceled
+func (bt *Bottalker) startWorkers(ctx context.Context) {
+	bt.getLogger().Debug("Starting workers")
+	bt.Lock()
+	bt.ctx = ctx
+	runtimes := make([]*botRuntime, len(bt.Bots))
+	bots := append([]*Bot(nil), bt.Bots...)
+	for i, b := range bots {
+		runtimes[i] = bt.attachBot(ctx, b)
+	}
+	bt.Unlock()
 
-	for _, b := range bt.Bots {
-		b.initBot(bt.TelegramClient, bt.ErrChan)
-		bt.wg.Add(1)
-		// TODO: Reporting bot status, not sure that we really need it
-		/*
-			go func(b *Bot) {
-				log.Println("Reporting in:", b.RepInterval)
-				c := time.Tick(b.RepInterval)
-				for nextTick := range c {
-					log.Println("Repored on:", nextTick)
-				}
-			}(b)
-		*/
-		go b.run(bt.ErrChan)
+	for i, b := range bots {
+		bt.startBot(b, runtimes[i])
 	}
-	bt.wg.Wait()
 }
 
 // WizardTargetEnum is enum to select desired wizard
@@ -253,12 +481,12 @@ func (bt *Bottalker) wizard(target WizardTargetEnum) error {
 	case WizardClient:
 		tc := bt.TelegramClient
 		for {
-			currentState, _ := tc.client.Authorize()
+			currentState, _ := tc.Backend.Authorize()
 			if currentState.GetAuthorizationStateEnum() == tdlib.AuthorizationStateWaitPhoneNumberType {
 				fmt.Printf("%s > Enter phone: ", tc.ID)
 				var number string
 				fmt.Scanln(&number)
-				_, err := tc.client.SendPhoneNumber(number)
+				_, err := tc.Backend.SendPhoneNumber(number)
 				if err != nil {
 					return fmt.Errorf("%s > Error sending phone number: %v", tc.ID, err)
 				}
@@ -266,7 +494,7 @@ func (bt *Bottalker) wizard(target WizardTargetEnum) error {
 				fmt.Printf("%s > Enter code: ", tc.ID)
 				var code string
 				fmt.Scanln(&code)
-				_, err := tc.client.SendAuthCode(code)
+				_, err := tc.Backend.SendAuthCode(code)
 				if err != nil {
 					return fmt.Errorf("%s > Error sending auth code : %v", tc.ID, err)
 				}
@@ -274,7 +502,7 @@ func (bt *Bottalker) wizard(target WizardTargetEnum) error {
 				fmt.Printf("%s > Enter Password: ", tc.ID)
 				var password string
 				fmt.Scanln(&password)
-				_, err := tc.client.SendAuthPassword(password)
+				_, err := tc.Backend.SendAuthPassword(password)
 				if err != nil {
 					return fmt.Errorf("%s > Error sending auth password: %v", tc.ID, err)
 				}
@@ -291,10 +519,11 @@ func (bt *Bottalker) wizard(target WizardTargetEnum) error {
 
 // TelegramClient is used to define client details
 type TelegramClient struct {
-	ID      string         // identifies clients including stored ones, so don't change between runs
-	client  *tdlib.Client  // instance of connected client goes here
-	Config  *tdlib.Config  // tdlib.Config, we have kinda working config with default values so you need to specify at least `APIID` and `APIHash`, ah, okay, you can specify nothig and use mine API related vals
-	Proxies []*ClientProxy // array of ClientProxiy; use them if telegram server can't be reached directly
+	ID      string          // identifies clients including stored ones, so don't change between runs
+	Backend TelegramBackend // instance of connected client goes here; tdlib client will be used if not specified
+	Config  *tdlib.Config   // tdlib.Config, we have kinda working config with default values so you need to specify at least `APIID` and `APIHash`, ah, okay, you can specify nothig and use mine API related vals
+	Proxies []*ClientProxy  // array of ClientProxiy; use them if telegram server can't be reached directly
+	chats   []*tdlib.Chat   // chat list loaded on connect
 }
 
 // ClientProxy used to define proto/socks/http proxy
@@ -305,44 +534,55 @@ type ClientProxy struct {
 	TypeParam tdlib.ProxyType // tdlib.ProxyType
 }
 
-func (tc *TelegramClient) getHistory(chatID int64) error {
-	lastbmsg, err := tc.getLastMsgID(chatID)
+func (tc *TelegramClient) initChatList(logger Logger) error {
+	allChats, err := tc.ChatList()
 	if err != nil {
 		return err
 	}
-	var bmsgLimit int32 = 8
-LOOP:
-	for {
-		bmsgs, err := tc.client.GetChatHistory(chatID, lastbmsg, 0, bmsgLimit, false)
-		if err != nil {
-			return fmt.Errorf("GetChatHistory failed: %v", err)
-		}
-		for _, bmsg := range bmsgs.Messages {
-			lastbmsg = bmsg.ID
-		}
-		if len(bmsgs.Messages) < int(bmsgLimit-1) {
-			break LOOP
-		}
-		time.Sleep(100 * time.Millisecond)
+
+	logger.Info("Got chats", "count", len(allChats))
+	for k, chat := range allChats {
+		logger.Debug("Chat", "index", k, "title", chat.Title, "chat_id", chat.ID)
 	}
+
+	tc.chats = allChats
 	return nil
 }
 
-func (tc *TelegramClient) initChatList() error {
+// Chats returns chat list loaded by `Bottalker.Connect` or `Bottalker.Run`, use `ChatList` to refetch it
+func (tc *TelegramClient) Chats() []*tdlib.Chat {
+	return tc.chats
+}
+
+// ChatList returns up to 100 chats from main chat list
+func (tc *TelegramClient) ChatList() ([]*tdlib.Chat, error) {
 	chatLimit := 100
 	allChats := make([]*tdlib.Chat, 0, chatLimit)
 	var haveFullChatList bool
 	err := tc.fetchChats(chatLimit, haveFullChatList, &allChats)
 	if err != nil {
-		return fmt.Errorf("fetchChats failed: %s", err)
+		return nil, fmt.Errorf("fetchChats failed: %s", err)
 	}
+	return allChats, nil
+}
 
-	log.Printf("%s > Got %d chats\n", tc.ID, len(allChats))
-	for k, chat := range allChats {
-		log.Printf("\t%d > Chat title: %s [%d]", k, chat.Title, chat.ID)
+// GetMessage returns chat message by ID, the latest one is returned if `msgID` is 0
+func (tc *TelegramClient) GetMessage(chatID int64, msgID int64) (*tdlib.Message, error) {
+	if msgID == 0 {
+		lastMsgID, err := tc.getLastMsgID(chatID)
+		if err != nil {
+			return nil, err
+		}
+		if lastMsgID == 0 {
+			return nil, fmt.Errorf("Chat [%d] has no messages", chatID)
+		}
+		msgID = lastMsgID
 	}
-
-	return nil
+	msg, err := tc.Backend.GetMessage(chatID, msgID)
+	if err != nil {
+		return nil, fmt.Errorf("GetMessage [%d] failed: %s", msgID, err)
+	}
+	return msg, nil
 }
 
 func (tc *TelegramClient) fetchChats(limit int, haveFullChatList bool, allChats *[]*tdlib.Chat) error {
@@ -357,7 +597,7 @@ func (tc *TelegramClient) fetchChats(limit int, haveFullChatList bool, allChats
 			offsetChatID = lastChat.ID
 		}
 
-		chats, err := tc.client.GetChats(nil, tdlib.JSONInt64(offsetOrder),
+		chats, err := tc.Backend.GetChats(nil, tdlib.JSONInt64(offsetOrder),
 			offsetChatID, int32(limit-len(*allChats)))
 		if err != nil {
 			return err
@@ -369,7 +609,7 @@ func (tc *TelegramClient) fetchChats(limit int, haveFullChatList bool, allChats
 
 		for _, chatID := range chats.ChatIDs {
 			// get chat info from tdlib
-			chat, err := tc.client.GetChat(chatID)
+			chat, err := tc.Backend.GetChat(chatID)
 			if err == nil {
 				*allChats = append(*allChats, chat)
 			} else {
@@ -382,7 +622,7 @@ func (tc *TelegramClient) fetchChats(limit int, haveFullChatList bool, allChats
 }
 
 func (tc *TelegramClient) getLastMsgID(chatID int64) (int64, error) {
-	chat, err := tc.client.GetChat(chatID)
+	chat, err := tc.Backend.GetChat(chatID)
 	if err != nil {
 		return int64(0), fmt.Errorf("GetChat faield: %v", err)
 	}
@@ -396,7 +636,7 @@ func (tc *TelegramClient) getLastMsgID(chatID int64) (int64, error) {
 }
 
 func (tc *TelegramClient) getMsgByDate(chatID int64, date int32, print bool) (*tdlib.Message, error) {
-	bmsg, err := tc.client.GetChatMessageByDate(chatID, date)
+	bmsg, err := tc.Backend.GetChatMessageByDate(chatID, date)
 	if err != nil {
 		return nil, fmt.Errorf("GetChatMessageByDate failed: %s", err)
 	}
@@ -404,19 +644,102 @@ func (tc *TelegramClient) getMsgByDate(chatID int64, date int32, print bool) (*t
 	return bmsg, nil
 }
 
+// sendText sends plain-text message to chat
+func (tc *TelegramClient) sendText(chatID int64, text string) (*tdlib.Message, error) {
+	return tc.Backend.SendMessage(chatID, int64(0), int64(0), tdlib.NewMessageSendOptions(false, false, nil), nil,
+		tdlib.NewInputMessageText(
+			tdlib.NewFormattedText(text, nil),
+			true,
+			true,
+		),
+	)
+}
+
 // MessageButton used to represent valuable attrs of bot keyboard buttons
 type MessageButton struct {
-	Text    string // Button caption
-	Payload []byte // Button command
+	Kind                    ButtonKindEnum // Button type
+	Text                    string         // Button caption
+	Payload                 []byte         // Button command: callback data, switch-inline query or text sent on reply keyboard button press
+	URL                     string         // URL and login URL buttons only; URL to open
+	ForwardText             string         // Login URL buttons only; button text in forwarded messages
+	SwitchInlineCurrentChat bool           // Switch-inline buttons only; inline query is sent from the current chat
+	Request                 string         // Reply keyboard only; what button requests from user, one of `ButtonRequest...`, empty for text buttons
+	Row                     int            // Keyboard row, starting from 0
+	Column                  int            // Position in row, starting from 0
 }
 
-// PrintMessage displays message content
-func PrintMessage(msg *tdlib.Message) {
-	log.Printf("Printing Message [%d @ %v]", msg.ID, time.Unix(int64(msg.Date), 0))
-	log.Printf("Text: %s", *GetMessageText(msg))
-	log.Println("Buttons:")
-	for _, btn := range GetMessageButtons(msg) {
-		log.Printf(`	%s: "%s"`, btn.Text, btn.Payload)
+// ButtonKindEnum is keyboard button type
+type ButtonKindEnum int
+
+// Enum to switch between button types
+const (
+	ButtonUnknown              ButtonKindEnum = iota // button type isn't supported
+	ButtonCallback                                   // inline button sending `Payload` to bot
+	ButtonCallbackWithPassword                       // inline button sending `Payload` to bot after password check
+	ButtonURL                                        // inline button opening `URL`
+	ButtonLoginURL                                   // inline button authorizing user on `URL`
+	ButtonSwitchInline                               // inline button starting inline query `Payload`
+	ButtonGame                                       // inline button starting game
+	ButtonBuy                                        // inline button paying invoice
+	ButtonKeyboard                                   // reply keyboard button
+)
+
+// String returns button type name
+func (bk ButtonKindEnum) String() string {
+	switch bk {
+	case ButtonCallback:
+		return "callback"
+	case ButtonCallbackWithPassword:
+		return "callback_with_password"
+	case ButtonURL:
+		return "url"
+	case ButtonLoginURL:
+		return "login_url"
+	case ButtonSwitchInline:
+		return "switch_inline"
+	case ButtonGame:
+		return "game"
+	case ButtonBuy:
+		return "buy"
+	case ButtonKeyboard:
+		return "keyboard"
+	}
+	return "unknown"
+}
+
+// IsCallback checks if button sends callback query on press
+func (mb *MessageButton) IsCallback() bool {
+	return mb.Kind == ButtonCallback || mb.Kind == ButtonCallbackWithPassword
+}
+
+// Reply keyboard button requests, see `MessageButton.Request`
+const (
+	ButtonRequestPhone    = "phone"
+	ButtonRequestLocation = "location"
+	ButtonRequestPoll     = "poll"
+)
+
+// PrintMessage logs message content with `Bottalker` logger, one entry per button
+func (bt *Bottalker) PrintMessage(msg *tdlib.Message) {
+	logger := bt.getLogger().With("msg_id", msg.ID)
+	keyvals := []interface{}{"date", time.Unix(int64(msg.Date), 0)}
+	if msg.Content != nil {
+		keyvals = append(keyvals, "content", msg.Content.GetMessageContentEnum())
+	}
+	if text := GetMessageText(msg); text != nil {
+		keyvals = append(keyvals, "text", *text)
+	}
+	buttons := GetMessageButtons(msg)
+	logger.Info("Message", append(keyvals, "buttons", len(buttons))...)
+	for _, btn := range buttons {
+		keyvals := []interface{}{"row", btn.Row, "column", btn.Column, "text", btn.Text, "kind", btn.Kind}
+		switch {
+		case btn.URL != "":
+			keyvals = append(keyvals, "url", btn.URL)
+		default:
+			keyvals = append(keyvals, "payload", btn.Payload)
+		}
+		logger.Info("Button", keyvals...)
 	}
 }
 
@@ -432,6 +755,30 @@ func GetMessageText(msg *tdlib.Message) *string {
 	return nil
 }
 
+// getReplyText returns text of incoming message, edited message content or callback answer,
+// `ok` is false for updates which are not replies (outgoing messages, markup edits)
+func getReplyText(msg *tdlib.TdMessage) (text string, ok bool) {
+	switch (*msg).(type) {
+	case *tdlib.CallbackQueryAnswer:
+		return (*msg).(*tdlib.CallbackQueryAnswer).Text, true
+	case *tdlib.UpdateMessageContent:
+		if content, isText := (*msg).(*tdlib.UpdateMessageContent).NewContent.(*tdlib.MessageText); isText {
+			return content.Text.Text, true
+		}
+		return "", true
+	case *tdlib.UpdateChatLastMessage:
+		lastMessage := (*msg).(*tdlib.UpdateChatLastMessage).LastMessage
+		if lastMessage == nil || lastMessage.IsOutgoing {
+			return "", false
+		}
+		if t := GetMessageText(lastMessage); t != nil {
+			return *t, true
+		}
+		return "", true
+	}
+	return "", false
+}
+
 // GetMessageButtons parses and returns array of MessageButton from Telegram message
 func GetMessageButtons(msg *tdlib.Message) []*MessageButton {
 	if msg.ReplyMarkup != nil {
@@ -439,19 +786,19 @@ func GetMessageButtons(msg *tdlib.Message) []*MessageButton {
 		rm := msg.ReplyMarkup
 		switch rm.(type) {
 		case *tdlib.ReplyMarkupShowKeyboard:
-			// I don't have an example with that kind of bot,
-			// skipped for now
-			/*
-				for i, row := range rm.(*tdlib.ReplyMarkupShowKeyboard).Rows {
-					for j, col := range row {
-						// buttons = append(buttons, decodeMessageButton(col))
-					}
+			for i, row := range rm.(*tdlib.ReplyMarkupShowKeyboard).Rows {
+				for j, col := range row {
+					btn := decodeKeyboardButton(&col)
+					btn.Row, btn.Column = i, j
+					buttons = append(buttons, btn)
 				}
-			*/
+			}
 		case *tdlib.ReplyMarkupInlineKeyboard:
-			for _, row := range rm.(*tdlib.ReplyMarkupInlineKeyboard).Rows {
-				for _, col := range row {
-					buttons = append(buttons, decodeMessageButton(&col))
+			for i, row := range rm.(*tdlib.ReplyMarkupInlineKeyboard).Rows {
+				for j, col := range row {
+					btn := decodeMessageButton(&col)
+					btn.Row, btn.Column = i, j
+					buttons = append(buttons, btn)
 				}
 			}
 		}
@@ -460,34 +807,65 @@ func GetMessageButtons(msg *tdlib.Message) []*MessageButton {
 	return nil
 }
 
-// TODO: Add KeyboardButton
 func decodeMessageButton(keyboardButton *tdlib.InlineKeyboardButton) *MessageButton {
 	messageButton := &MessageButton{
 		Text: keyboardButton.Text,
 	}
 	switch keyboardButton.Type.(type) {
 	case *tdlib.InlineKeyboardButtonTypeCallback:
+		messageButton.Kind = ButtonCallback
 		messageButton.Payload = keyboardButton.Type.(*tdlib.InlineKeyboardButtonTypeCallback).Data
 	case *tdlib.InlineKeyboardButtonTypeCallbackWithPassword:
+		messageButton.Kind = ButtonCallbackWithPassword
 		messageButton.Payload = keyboardButton.Type.(*tdlib.InlineKeyboardButtonTypeCallbackWithPassword).Data
 	case *tdlib.InlineKeyboardButtonTypeSwitchInline:
-		messageButton.Payload = []byte(keyboardButton.Type.(*tdlib.InlineKeyboardButtonTypeSwitchInline).Query)
+		switchInline := keyboardButton.Type.(*tdlib.InlineKeyboardButtonTypeSwitchInline)
+		messageButton.Kind = ButtonSwitchInline
+		messageButton.Payload = []byte(switchInline.Query)
+		messageButton.SwitchInlineCurrentChat = switchInline.InCurrentChat
+	case *tdlib.InlineKeyboardButtonTypeURL:
+		messageButton.Kind = ButtonURL
+		messageButton.URL = keyboardButton.Type.(*tdlib.InlineKeyboardButtonTypeURL).URL
+	case *tdlib.InlineKeyboardButtonTypeLoginURL:
+		loginURL := keyboardButton.Type.(*tdlib.InlineKeyboardButtonTypeLoginURL)
+		messageButton.Kind = ButtonLoginURL
+		messageButton.URL = loginURL.URL
+		messageButton.ForwardText = loginURL.ForwardText
+	case *tdlib.InlineKeyboardButtonTypeCallbackGame:
+		messageButton.Kind = ButtonGame
+	case *tdlib.InlineKeyboardButtonTypeBuy:
+		messageButton.Kind = ButtonBuy
 	}
 	return messageButton
 }
 
-// Creates dirs by full path
-func createDir(path string) {
-	if stats, err := os.Stat(path); os.IsNotExist(err) {
-		dir, err := filepath.Abs(filepath.Dir(path))
-		if err != nil {
-			fmt.Println("err:", err)
-		}
-		err = os.MkdirAll(dir, 0700)
-		if err != nil {
-			fmt.Println("err:", err)
-		}
-	} else {
-		fmt.Println(stats)
+// decodeKeyboardButton decodes reply keyboard button, pressing it sends its text unless it requests something
+func decodeKeyboardButton(keyboardButton *tdlib.KeyboardButton) *MessageButton {
+	messageButton := &MessageButton{
+		Kind: ButtonKeyboard,
+		Text: keyboardButton.Text,
+	}
+	switch keyboardButton.Type.(type) {
+	case *tdlib.KeyboardButtonTypeRequestPhoneNumber:
+		messageButton.Request = ButtonRequestPhone
+	case *tdlib.KeyboardButtonTypeRequestLocation:
+		messageButton.Request = ButtonRequestLocation
+	case *tdlib.KeyboardButtonTypeRequestPoll:
+		messageButton.Request = ButtonRequestPoll
+	default:
+		messageButton.Payload = []byte(keyboardButton.Text)
+	}
+	return messageButton
+}
+
+// Creates parent dirs of file by full path
+func createDir(path string) error {
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return nil
+	}
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return err
 	}
+	return os.MkdirAll(dir, 0700)
 }
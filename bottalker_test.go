@@ -0,0 +1,263 @@
+package bottalker_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+func TestRunWithFakeServer(t *testing.T) {
+	srv := bottalkertest.NewServer()
+
+	chatBot := srv.AddBot(600120108, "QBot")
+	chatBot.Send("Welcome", nil)
+	chatBot.OnText("/bal_btc").Reply("BTC: 1.0", bottalkertest.InlineKeyboard(
+		bottalkertest.Row(bottalkertest.CallbackButton("Refresh", "/bal_btc")),
+	))
+
+	payloadBot := srv.AddBot(600120109, "PBot")
+	payloadBot.Send("Balance", bottalkertest.InlineKeyboard(
+		bottalkertest.Row(bottalkertest.CallbackButton("GST", "/bal_gst")),
+	))
+	payloadBot.OnCallback("/bal_gst").Answer("Updated", false).Edit("GST: 2.0", nil)
+
+	chatReplies := make(chan *bottalker.Reply, 100)
+	payloadReplies := make(chan *bottalker.Reply, 100)
+	runBottalker(t, srv,
+		&bottalker.Bot{
+			Label:       "QBot",
+			ChatID:      chatBot.ID,
+			ChkInterval: 10 * time.Millisecond,
+			Replies:     chatReplies,
+			Commands: []bottalker.BotCommandType{
+				&bottalker.BotCommandChat{
+					BotCommand: bottalker.BotCommand{
+						Data: []byte("/bal_btc"),
+					},
+				},
+			},
+		},
+		&bottalker.Bot{
+			Label:       "PBot",
+			ChatID:      payloadBot.ID,
+			ChkInterval: 10 * time.Millisecond,
+			Replies:     payloadReplies,
+			Commands: []bottalker.BotCommandType{
+				&bottalker.BotCommandPayload{
+					BotCommand: bottalker.BotCommand{
+						Data: []byte("/bal_gst"),
+					},
+				},
+			},
+		},
+	)
+
+	timeout := time.After(5 * time.Second)
+	var gotChat, gotPayload bool
+	for !gotChat || !gotPayload {
+		select {
+		case reply := <-chatReplies:
+			if reply.Kind != bottalker.ReplyNew {
+				t.Fatalf("unexpected reply kind: %s", reply.Kind)
+			}
+			if reply.Text != "BTC: 1.0" || reply.Bot.Label != "QBot" || reply.Command != reply.Bot.Commands[0] {
+				t.Fatalf("unexpected reply: %+v", reply)
+			}
+			if _, ok := reply.Update.(*tdlib.UpdateChatLastMessage); !ok || reply.Message.IsOutgoing {
+				t.Fatalf("unexpected update: %+v", reply.Update)
+			}
+			if len(reply.Buttons) != 1 || reply.Buttons[0].Text != "Refresh" || string(reply.Buttons[0].Payload) != "/bal_btc" {
+				t.Fatalf("unexpected buttons: %+v", reply.Buttons)
+			}
+			gotChat = true
+		case reply := <-payloadReplies:
+			if reply.Kind != bottalker.ReplyEditedContent {
+				continue
+			}
+			if reply.Text != "GST: 2.0" || reply.Message == nil {
+				t.Fatalf("unexpected edit: %+v", reply)
+			}
+			if text := bottalker.GetMessageText(reply.Message); text == nil || *text != "GST: 2.0" {
+				t.Fatalf("edited message isn't fetched: %+v", reply.Message.Content)
+			}
+			gotPayload = true
+		case <-timeout:
+			t.Fatalf("replies not received, chat: %v, payload: %v", gotChat, gotPayload)
+		}
+	}
+}
+
+func TestRunShutdown(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	rule := qbot.OnText("/bal_btc").Reply("BTC: 1.0", nil)
+
+	replies := make(chan *bottalker.Reply)
+	bt := &bottalker.Bottalker{
+		TelegramClient: &bottalker.TelegramClient{
+			ID:      "test",
+			Backend: srv,
+		},
+		Bots: []*bottalker.Bot{
+			{
+				Label:       "QBot",
+				ChatID:      qbot.ID,
+				ChkInterval: 10 * time.Millisecond,
+				Replies:     replies, // nobody reads it, handlers shouldn't block shutdown
+				Commands: []bottalker.BotCommandType{
+					&bottalker.BotCommandChat{
+						BotCommand: bottalker.BotCommand{
+							Data: []byte("/bal_btc"),
+						},
+					},
+				},
+			},
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- bt.Run(ctx)
+	}()
+
+	waitFor(t, 5*time.Second, func() bool {
+		return rule.Hits() > 0
+	})
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run didn't stop")
+	}
+	if !srv.Destroyed() {
+		t.Error("backend is not destroyed")
+	}
+
+	hits := rule.Hits()
+	time.Sleep(50 * time.Millisecond)
+	if rule.Hits() != hits {
+		t.Error("command triggered after shutdown")
+	}
+}
+
+// runBottalker starts Bottalker with fake server as backend, it's stopped on test cleanup
+func runBottalker(t *testing.T, srv *bottalkertest.Server, bots ...*bottalker.Bot) *bottalker.Bottalker {
+	bt := &bottalker.Bottalker{
+		TelegramClient: &bottalker.TelegramClient{
+			ID:      "test",
+			Backend: srv,
+		},
+		Bots: bots,
+	}
+	return startBottalker(t, bt)
+}
+
+// startBottalker runs `bt` until test is finished
+func startBottalker(t *testing.T, bt *bottalker.Bottalker) *bottalker.Bottalker {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- bt.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Run failed: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("Run didn't stop")
+		}
+	})
+	return bt
+}
+
+// waitFor polls `cond` until it's true or fails test after timeout
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met in %v", timeout)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestInspectNonTextMessage(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.SendContent(tdlib.NewMessageSticker(&tdlib.Sticker{Emoji: "👍"}), bottalkertest.InlineKeyboard(
+		bottalkertest.Row(bottalkertest.CallbackButton("Like", "/like")),
+	))
+	tc := &bottalker.TelegramClient{ID: "test", Backend: srv}
+
+	msg, err := tc.GetMessage(qbot.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	bt := &bottalker.Bottalker{TelegramClient: tc, Logger: bottalker.NewJSONLogger(&buf, bottalker.LogInfo)}
+	bt.PrintMessage(msg)
+
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("unparseable log line %q: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 || entries[0]["content"] != "messageSticker" || entries[0]["text"] != nil ||
+		entries[1]["text"] != "Like" || entries[1]["kind"] != "callback" || entries[1]["payload"] != "/like" {
+		t.Errorf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestLoginProxies(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	bt := &bottalker.Bottalker{
+		TelegramClient: &bottalker.TelegramClient{
+			ID:      "test",
+			Backend: srv,
+			Proxies: []*bottalker.ClientProxy{
+				{Server: "127.0.0.1", Port: 1080, Enable: true, TypeParam: tdlib.NewProxyTypeSocks5("", "")},
+			},
+		},
+	}
+	if err := bt.Login(); err != nil {
+		t.Fatal(err)
+	}
+	if proxies := srv.Proxies(); len(proxies) != 1 || proxies[0].Server != "127.0.0.1" || proxies[0].Port != 1080 {
+		t.Errorf("unexpected proxies: %+v", proxies)
+	}
+}
+
+func TestConnectChats(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	bt := &bottalker.Bottalker{
+		TelegramClient: &bottalker.TelegramClient{
+			ID:      "test",
+			Backend: srv,
+		},
+	}
+	if err := bt.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	if chats := bt.TelegramClient.Chats(); len(chats) != 1 || chats[0].ID != qbot.ID || chats[0].Title != "QBot" {
+		t.Errorf("unexpected chats: %+v", chats)
+	}
+}
@@ -0,0 +1,250 @@
+package bottalkertest
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/Arman92/go-tdlib"
+)
+
+// Bot is scripted Telegram bot, it reacts on messages and button presses by declared rules
+type Bot struct {
+	ID    int64  // bot user ID, same as private chat ID
+	Title string // chat title
+
+	srv   *Server
+	rules []*Rule
+	sync.Mutex
+}
+
+// ruleKind separates text rules from callback ones
+type ruleKind int
+
+const (
+	ruleText ruleKind = iota
+	ruleCallback
+)
+
+// Rule describes bot reaction on matched text message or callback query
+type Rule struct {
+	kind   ruleKind
+	match  func(data string) bool
+	steps  []func(b *Bot, trigger *tdlib.Message)
+	answer *tdlib.CallbackQueryAnswer
+	err    error
+	fails  int // matches left to fail, negative means all of them
+	hits   int
+	sync.Mutex
+}
+
+// OnText declares reaction on exact text message
+func (b *Bot) OnText(text string) *Rule {
+	return b.addRule(ruleText, func(data string) bool {
+		return data == text
+	})
+}
+
+// OnTextMatch declares reaction on text message matched by regexp
+func (b *Bot) OnTextMatch(re *regexp.Regexp) *Rule {
+	return b.addRule(ruleText, re.MatchString)
+}
+
+// OnCallback declares reaction on inline button with `data` payload
+func (b *Bot) OnCallback(data string) *Rule {
+	return b.addRule(ruleCallback, func(payload string) bool {
+		return payload == data
+	})
+}
+
+// Send posts message from bot without any trigger, e.g. welcome message with keyboard
+func (b *Bot) Send(text string, replyMarkup tdlib.ReplyMarkup) *tdlib.Message {
+	return b.SendContent(textContent(text), replyMarkup)
+}
+
+// SendContent posts message with arbitrary content from bot, e.g. sticker or photo
+func (b *Bot) SendContent(content tdlib.MessageContent, replyMarkup tdlib.ReplyMarkup) *tdlib.Message {
+	s := b.srv
+	s.mu.Lock()
+	msg, updates := s.addMessage(s.chats[b.ID], int32(b.ID), content, replyMarkup, 0)
+	s.mu.Unlock()
+	s.dispatch(updates)
+	return copyMessage(msg)
+}
+
+func (b *Bot) addRule(kind ruleKind, match func(data string) bool) *Rule {
+	b.Lock()
+	defer b.Unlock()
+	r := &Rule{
+		kind:  kind,
+		match: match,
+	}
+	b.rules = append(b.rules, r)
+	return r
+}
+
+// findRule returns the first declared rule matching data
+func (b *Bot) findRule(kind ruleKind, data string) *Rule {
+	b.Lock()
+	defer b.Unlock()
+	for _, r := range b.rules {
+		if r.kind == kind && r.match(data) {
+			return r
+		}
+	}
+	return nil
+}
+
+// handleText runs text rule for incoming message
+func (b *Bot) handleText(msg *tdlib.Message) error {
+	content, ok := msg.Content.(*tdlib.MessageText)
+	if !ok {
+		return nil
+	}
+	r := b.findRule(ruleText, content.Text.Text)
+	if r == nil {
+		return nil
+	}
+	if err := r.hit(); err != nil {
+		return err
+	}
+	r.run(b, msg)
+	return nil
+}
+
+// handleCallback runs callback rule for pressed button
+func (b *Bot) handleCallback(msg *tdlib.Message, data []byte) (*tdlib.CallbackQueryAnswer, error) {
+	r := b.findRule(ruleCallback, string(data))
+	if r == nil {
+		return nil, ErrTimeout
+	}
+	if err := r.hit(); err != nil {
+		return nil, err
+	}
+	r.run(b, msg)
+
+	r.Lock()
+	defer r.Unlock()
+	if r.answer == nil {
+		return &tdlib.CallbackQueryAnswer{}, nil
+	}
+	answer := *r.answer
+	return &answer, nil
+}
+
+// Reply sends new message from bot as reply to trigger
+func (r *Rule) Reply(text string, replyMarkup tdlib.ReplyMarkup) *Rule {
+	return r.addStep(func(b *Bot, trigger *tdlib.Message) {
+		s := b.srv
+		s.mu.Lock()
+		var replyTo int64
+		if r.kind == ruleText {
+			replyTo = trigger.ID
+		}
+		_, updates := s.addMessage(s.chats[b.ID], int32(b.ID), textContent(text), replyMarkup, replyTo)
+		s.mu.Unlock()
+		s.dispatch(updates)
+	})
+}
+
+// Edit replaces text and markup of the message with pressed button,
+// for text rules the latest bot message will be edited
+func (r *Rule) Edit(text string, replyMarkup tdlib.ReplyMarkup) *Rule {
+	return r.addStep(func(b *Bot, trigger *tdlib.Message) {
+		b.edit(r.kind, trigger, &text, replyMarkup)
+	})
+}
+
+// EditMarkup replaces only markup of the message, see `Edit`
+func (r *Rule) EditMarkup(replyMarkup tdlib.ReplyMarkup) *Rule {
+	return r.addStep(func(b *Bot, trigger *tdlib.Message) {
+		b.edit(r.kind, trigger, nil, replyMarkup)
+	})
+}
+
+// Answer sets callback query answer shown as toast or alert
+func (r *Rule) Answer(text string, showAlert bool) *Rule {
+	r.Lock()
+	defer r.Unlock()
+	r.answer = &tdlib.CallbackQueryAnswer{
+		Text:      text,
+		ShowAlert: showAlert,
+	}
+	return r
+}
+
+// Fail makes matched call return `err` instead of running rule
+func (r *Rule) Fail(err error) *Rule {
+	return r.FailN(-1, err)
+}
+
+// FailN makes the next `n` matched calls return `err`, rule runs as usual afterwards
+func (r *Rule) FailN(n int, err error) *Rule {
+	r.Lock()
+	defer r.Unlock()
+	r.err = err
+	r.fails = n
+	return r
+}
+
+// Hits returns how many times rule was matched
+func (r *Rule) Hits() int {
+	r.Lock()
+	defer r.Unlock()
+	return r.hits
+}
+
+func (r *Rule) addStep(step func(b *Bot, trigger *tdlib.Message)) *Rule {
+	r.Lock()
+	defer r.Unlock()
+	r.steps = append(r.steps, step)
+	return r
+}
+
+// hit counts match and returns configured failure
+func (r *Rule) hit() error {
+	r.Lock()
+	defer r.Unlock()
+	r.hits++
+	if r.err == nil || r.fails == 0 {
+		return nil
+	}
+	if r.fails > 0 {
+		r.fails--
+	}
+	return r.err
+}
+
+func (r *Rule) run(b *Bot, trigger *tdlib.Message) {
+	r.Lock()
+	steps := make([]func(b *Bot, trigger *tdlib.Message), len(r.steps))
+	copy(steps, r.steps)
+	r.Unlock()
+	for _, step := range steps {
+		step(b, trigger)
+	}
+}
+
+// edit changes trigger message for callbacks or the latest bot message for text
+func (b *Bot) edit(kind ruleKind, trigger *tdlib.Message, text *string, replyMarkup tdlib.ReplyMarkup) {
+	s := b.srv
+	s.mu.Lock()
+	c := s.chats[b.ID]
+	var target *tdlib.Message
+	if kind == ruleCallback {
+		target = c.message(trigger.ID)
+	} else {
+		for i := len(c.messages) - 1; i >= 0; i-- {
+			if !c.messages[i].IsOutgoing {
+				target = c.messages[i]
+				break
+			}
+		}
+	}
+	if target == nil {
+		s.mu.Unlock()
+		return
+	}
+	updates := s.editMessage(c, target, text, replyMarkup)
+	s.mu.Unlock()
+	s.dispatch(updates)
+}
@@ -0,0 +1,80 @@
+package bottalkertest
+
+import (
+	"github.com/Arman92/go-tdlib"
+)
+
+// InlineKeyboard builds inline keyboard markup from rows
+func InlineKeyboard(rows ...[]tdlib.InlineKeyboardButton) *tdlib.ReplyMarkupInlineKeyboard {
+	return tdlib.NewReplyMarkupInlineKeyboard(rows)
+}
+
+// Row groups inline buttons into keyboard row
+func Row(buttons ...tdlib.InlineKeyboardButton) []tdlib.InlineKeyboardButton {
+	return buttons
+}
+
+// CallbackButton creates inline button sending `data` on press
+func CallbackButton(text string, data string) tdlib.InlineKeyboardButton {
+	return *tdlib.NewInlineKeyboardButton(text, tdlib.NewInlineKeyboardButtonTypeCallback([]byte(data)))
+}
+
+// Keyboard builds reply keyboard markup from rows
+func Keyboard(rows ...[]tdlib.KeyboardButton) *tdlib.ReplyMarkupShowKeyboard {
+	return tdlib.NewReplyMarkupShowKeyboard(rows, true, false, false)
+}
+
+// RemoveKeyboard builds markup which hides reply keyboard
+func RemoveKeyboard() *tdlib.ReplyMarkupRemoveKeyboard {
+	return tdlib.NewReplyMarkupRemoveKeyboard(false)
+}
+
+// KeyboardRow groups reply keyboard buttons into keyboard row
+func KeyboardRow(buttons ...tdlib.KeyboardButton) []tdlib.KeyboardButton {
+	return buttons
+}
+
+// TextButton creates reply keyboard button sending its text on press
+func TextButton(text string) tdlib.KeyboardButton {
+	return *tdlib.NewKeyboardButton(text, tdlib.NewKeyboardButtonTypeText())
+}
+
+// PhoneButton creates reply keyboard button requesting user's phone number
+func PhoneButton(text string) tdlib.KeyboardButton {
+	return *tdlib.NewKeyboardButton(text, tdlib.NewKeyboardButtonTypeRequestPhoneNumber())
+}
+
+// LocationButton creates reply keyboard button requesting user's location
+func LocationButton(text string) tdlib.KeyboardButton {
+	return *tdlib.NewKeyboardButton(text, tdlib.NewKeyboardButtonTypeRequestLocation())
+}
+
+// PollButton creates reply keyboard button requesting user to create poll
+func PollButton(text string) tdlib.KeyboardButton {
+	return *tdlib.NewKeyboardButton(text, tdlib.NewKeyboardButtonTypeRequestPoll(false, false))
+}
+
+// URLButton creates inline button opening `url`
+func URLButton(text string, url string) tdlib.InlineKeyboardButton {
+	return *tdlib.NewInlineKeyboardButton(text, tdlib.NewInlineKeyboardButtonTypeURL(url))
+}
+
+// LoginURLButton creates inline button authorizing user on `url`
+func LoginURLButton(text string, url string, forwardText string) tdlib.InlineKeyboardButton {
+	return *tdlib.NewInlineKeyboardButton(text, tdlib.NewInlineKeyboardButtonTypeLoginURL(url, 0, forwardText))
+}
+
+// SwitchInlineButton creates inline button starting inline `query`
+func SwitchInlineButton(text string, query string, inCurrentChat bool) tdlib.InlineKeyboardButton {
+	return *tdlib.NewInlineKeyboardButton(text, tdlib.NewInlineKeyboardButtonTypeSwitchInline(query, inCurrentChat))
+}
+
+// GameButton creates inline button starting game
+func GameButton(text string) tdlib.InlineKeyboardButton {
+	return *tdlib.NewInlineKeyboardButton(text, tdlib.NewInlineKeyboardButtonTypeCallbackGame())
+}
+
+// BuyButton creates inline button paying invoice
+func BuyButton(text string) tdlib.InlineKeyboardButton {
+	return *tdlib.NewInlineKeyboardButton(text, tdlib.NewInlineKeyboardButtonTypeBuy())
+}
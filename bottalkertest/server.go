@@ -0,0 +1,469 @@
+// Package bottalkertest provides in-memory Telegram server to run bottalker without real account
+//
+// Server implements `bottalker.TelegramBackend`, so it can be plugged into `bottalker.TelegramClient.Backend`:
+//
+//	srv := bottalkertest.NewServer()
+//	qbot := srv.AddBot(600120108, "QBot")
+//	qbot.OnText("/bal_btc").Reply("BTC: 1.0", bottalkertest.InlineKeyboard(
+//		bottalkertest.Row(bottalkertest.CallbackButton("Refresh", "/bal_btc")),
+//	))
+//	qbot.OnCallback("/bal_btc").Answer("Updated", false).Edit("BTC: 1.1", nil)
+//
+//	bt := &bottalker.Bottalker{
+//		TelegramClient: &bottalker.TelegramClient{ID: "test", Backend: srv},
+//		Bots:           bots,
+//	}
+package bottalkertest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+	"github.com/cdtj/bottalker-go"
+)
+
+// DefaultSelfID is user ID of the account we are logged in with
+const DefaultSelfID int32 = 1
+
+// Errors returned by Server the same way tdlib does
+var (
+	ErrChatNotFound    = errors.New("Chat not found")
+	ErrMessageNotFound = errors.New("Message not found")
+	ErrTimeout         = errors.New("timeout") // bot didn't answer callback query
+)
+
+// Server is in-memory Telegram emulation
+type Server struct {
+	SelfID int32 // our user ID, messages sent via backend will have it as sender
+
+	mu         sync.Mutex
+	chats      map[int64]*chat
+	chatOrder  []int64
+	nextMsgID  int64
+	receivers  []*receiver
+	authState  tdlib.AuthorizationState
+	proxies    []*tdlib.Proxy
+	destroyed  bool
+	dispatchMu sync.Mutex // keeps updates ordered and guards receiver channels
+}
+
+// chat holds history of single conversation
+type chat struct {
+	chat     tdlib.Chat
+	messages []*tdlib.Message // sorted by ID
+	bot      *Bot
+}
+
+// receiver is subscription created by `AddEventReceiver`
+type receiver struct {
+	instance tdlib.TdMessage
+	filter   tdlib.EventFilterFunc
+	ch       chan tdlib.TdMessage
+	done     chan struct{}
+}
+
+// NewServer creates Server with authorized account and no chats
+func NewServer() *Server {
+	return &Server{
+		SelfID:    DefaultSelfID,
+		chats:     make(map[int64]*chat),
+		authState: tdlib.NewAuthorizationStateReady(),
+	}
+}
+
+// AddBot creates private chat with scripted bot, `chatID` is bot user ID as well
+func (s *Server) AddBot(chatID int64, title string) *Bot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := &Bot{
+		ID:    chatID,
+		Title: title,
+		srv:   s,
+	}
+	c := &chat{
+		chat: tdlib.Chat{
+			ID:    chatID,
+			Type:  tdlib.NewChatTypePrivate(int32(chatID)),
+			Title: title,
+		},
+		bot: b,
+	}
+	c.chat.Positions = []tdlib.ChatPosition{
+		*tdlib.NewChatPosition(tdlib.NewChatListMain(), tdlib.JSONInt64(math.MaxInt32-len(s.chatOrder)), false, nil),
+	}
+	s.chats[chatID] = c
+	s.chatOrder = append(s.chatOrder, chatID)
+	return b
+}
+
+// SetAuthorizationState overrides state returned by `Authorize`, use it to emulate login flow
+func (s *Server) SetAuthorizationState(state tdlib.AuthorizationState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authState = state
+}
+
+// SetConnectionState sends `tdlib.UpdateConnectionState` to subscribed receivers, use it to emulate network issues
+func (s *Server) SetConnectionState(state tdlib.ConnectionState) {
+	s.dispatch([]tdlib.TdMessage{tdlib.NewUpdateConnectionState(state)})
+}
+
+// Messages returns copy of chat history, oldest first
+func (s *Server) Messages(chatID int64) []*tdlib.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.chats[chatID]
+	if !ok {
+		return nil
+	}
+	msgs := make([]*tdlib.Message, 0, len(c.messages))
+	for _, msg := range c.messages {
+		msgs = append(msgs, copyMessage(msg))
+	}
+	return msgs
+}
+
+// LastMessage returns copy of the latest chat message or nil
+func (s *Server) LastMessage(chatID int64) *tdlib.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.chats[chatID]
+	if !ok || len(c.messages) == 0 {
+		return nil
+	}
+	return copyMessage(c.messages[len(c.messages)-1])
+}
+
+// Destroyed reports if `DestroyInstance` was called
+func (s *Server) Destroyed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.destroyed
+}
+
+// Proxies returns proxies added by `AddProxy`
+func (s *Server) Proxies() []*tdlib.Proxy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*tdlib.Proxy(nil), s.proxies...)
+}
+
+// Receivers returns number of active `AddEventReceiver` subscriptions
+func (s *Server) Receivers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.receivers)
+}
+
+// SendMessage stores outgoing message and lets chat bot react on it
+func (s *Server) SendMessage(chatID int64, messageThreadID int64, replyToMessageID int64, options *tdlib.MessageSendOptions, replyMarkup tdlib.ReplyMarkup, inputMessageContent tdlib.InputMessageContent) (*tdlib.Message, error) {
+	var text string
+	switch content := inputMessageContent.(type) {
+	case *tdlib.InputMessageText:
+		if content.Text != nil {
+			text = content.Text.Text
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported input message content: %T", inputMessageContent)
+	}
+
+	s.mu.Lock()
+	c, ok := s.chats[chatID]
+	if !ok {
+		s.mu.Unlock()
+		return nil, ErrChatNotFound
+	}
+	msg, updates := s.addMessage(c, s.SelfID, textContent(text), replyMarkup, replyToMessageID)
+	s.mu.Unlock()
+	s.dispatch(updates)
+
+	if c.bot != nil {
+		if err := c.bot.handleText(msg); err != nil {
+			return nil, err
+		}
+	}
+	return copyMessage(msg), nil
+}
+
+// GetCallbackQueryAnswer presses inline button and returns bot's answer
+func (s *Server) GetCallbackQueryAnswer(chatID int64, messageID int64, payload tdlib.CallbackQueryPayload) (*tdlib.CallbackQueryAnswer, error) {
+	s.mu.Lock()
+	c, ok := s.chats[chatID]
+	if !ok {
+		s.mu.Unlock()
+		return nil, ErrChatNotFound
+	}
+	msg := c.message(messageID)
+	s.mu.Unlock()
+	if msg == nil {
+		return nil, ErrMessageNotFound
+	}
+	if c.bot == nil {
+		return nil, ErrTimeout
+	}
+
+	var data []byte
+	switch p := payload.(type) {
+	case *tdlib.CallbackQueryPayloadData:
+		data = p.Data
+	case *tdlib.CallbackQueryPayloadDataWithPassword:
+		data = p.Data
+	case *tdlib.CallbackQueryPayloadGame:
+		data = []byte(p.GameShortName)
+	default:
+		return nil, fmt.Errorf("Unsupported callback query payload: %T", payload)
+	}
+	return c.bot.handleCallback(msg, data)
+}
+
+// GetMessage returns chat message by ID
+func (s *Server) GetMessage(chatID int64, messageID int64) (*tdlib.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.chats[chatID]
+	if !ok {
+		return nil, ErrChatNotFound
+	}
+	msg := c.message(messageID)
+	if msg == nil {
+		return nil, ErrMessageNotFound
+	}
+	return copyMessage(msg), nil
+}
+
+// GetChat returns chat info including its last message
+func (s *Server) GetChat(chatID int64) (*tdlib.Chat, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.chats[chatID]
+	if !ok {
+		return nil, ErrChatNotFound
+	}
+	return c.info(), nil
+}
+
+// GetChats returns chat IDs ordered the same way they were added
+func (s *Server) GetChats(chatList tdlib.ChatList, offsetOrder tdlib.JSONInt64, offsetChatID int64, limit int32) (*tdlib.Chats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	chatIDs := make([]int64, 0)
+	for _, chatID := range s.chatOrder {
+		c := s.chats[chatID]
+		order := c.chat.Positions[0].Order
+		if order > offsetOrder || (order == offsetOrder && chatID >= offsetChatID) {
+			continue
+		}
+		if int32(len(chatIDs)) >= limit {
+			break
+		}
+		chatIDs = append(chatIDs, chatID)
+	}
+	return tdlib.NewChats(int32(len(chatIDs)), chatIDs), nil
+}
+
+// GetChatHistory returns messages starting from `fromMessageID` (inclusive) and older, newest first
+func (s *Server) GetChatHistory(chatID int64, fromMessageID int64, offset int32, limit int32, onlyLocal bool) (*tdlib.Messages, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.chats[chatID]
+	if !ok {
+		return nil, ErrChatNotFound
+	}
+	start := len(c.messages) - 1
+	if fromMessageID != 0 {
+		start = sort.Search(len(c.messages), func(i int) bool {
+			return c.messages[i].ID > fromMessageID
+		}) - 1
+	}
+	start -= int(offset)
+	if start >= len(c.messages) {
+		start = len(c.messages) - 1
+	}
+	msgs := make([]tdlib.Message, 0, limit)
+	for i := start; i >= 0 && int32(len(msgs)) < limit; i-- {
+		msgs = append(msgs, *copyMessage(c.messages[i]))
+	}
+	return tdlib.NewMessages(int32(len(msgs)), msgs), nil
+}
+
+// GetChatMessageByDate returns the last message sent not later than `date`
+func (s *Server) GetChatMessageByDate(chatID int64, date int32) (*tdlib.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.chats[chatID]
+	if !ok {
+		return nil, ErrChatNotFound
+	}
+	for i := len(c.messages) - 1; i >= 0; i-- {
+		if c.messages[i].Date <= date {
+			return copyMessage(c.messages[i]), nil
+		}
+	}
+	return nil, ErrMessageNotFound
+}
+
+// Authorize returns current authorization state, `AuthorizationStateReady` by default
+func (s *Server) Authorize() (tdlib.AuthorizationState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.authState, nil
+}
+
+// SendPhoneNumber moves authorization to code awaiting
+func (s *Server) SendPhoneNumber(phoneNumber string) (tdlib.AuthorizationState, error) {
+	s.SetAuthorizationState(tdlib.NewAuthorizationStateWaitCode(nil))
+	return s.Authorize()
+}
+
+// SendAuthCode completes authorization
+func (s *Server) SendAuthCode(code string) (tdlib.AuthorizationState, error) {
+	s.SetAuthorizationState(tdlib.NewAuthorizationStateReady())
+	return s.Authorize()
+}
+
+// SendAuthPassword completes authorization
+func (s *Server) SendAuthPassword(password string) (tdlib.AuthorizationState, error) {
+	s.SetAuthorizationState(tdlib.NewAuthorizationStateReady())
+	return s.Authorize()
+}
+
+// AddProxy remembers proxy, it's never used
+func (s *Server) AddProxy(server string, port int32, enable bool, typeParam tdlib.ProxyType) (*tdlib.Proxy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	proxy := tdlib.NewProxy(int32(len(s.proxies)+1), server, port, 0, enable, typeParam)
+	s.proxies = append(s.proxies, proxy)
+	return proxy, nil
+}
+
+// AddEventReceiver subscribes to updates of `msgInstance` type
+func (s *Server) AddEventReceiver(msgInstance tdlib.TdMessage, filterFunc tdlib.EventFilterFunc, channelCapacity int) *bottalker.EventReceiver {
+	r := &receiver{
+		instance: msgInstance,
+		filter:   filterFunc,
+		ch:       make(chan tdlib.TdMessage, channelCapacity),
+		done:     make(chan struct{}),
+	}
+	s.mu.Lock()
+	s.receivers = append(s.receivers, r)
+	s.mu.Unlock()
+
+	return bottalker.NewEventReceiver(r.ch, func() {
+		s.mu.Lock()
+		for i, sr := range s.receivers {
+			if sr == r {
+				s.receivers = append(s.receivers[:i], s.receivers[i+1:]...)
+				break
+			}
+		}
+		s.mu.Unlock()
+		close(r.done)
+		s.dispatchMu.Lock()
+		close(r.ch)
+		s.dispatchMu.Unlock()
+	})
+}
+
+// DestroyInstance marks server as destroyed
+func (s *Server) DestroyInstance() {
+	s.mu.Lock()
+	s.destroyed = true
+	s.mu.Unlock()
+}
+
+// addMessage appends new message to chat, must be called under lock
+func (s *Server) addMessage(c *chat, senderID int32, content tdlib.MessageContent, replyMarkup tdlib.ReplyMarkup, replyToMessageID int64) (*tdlib.Message, []tdlib.TdMessage) {
+	s.nextMsgID++
+	msg := &tdlib.Message{
+		ID:               s.nextMsgID,
+		Sender:           tdlib.NewMessageSenderUser(senderID),
+		ChatID:           c.chat.ID,
+		IsOutgoing:       senderID == s.SelfID,
+		Date:             int32(time.Now().Unix()),
+		ReplyToMessageID: replyToMessageID,
+		Content:          content,
+		ReplyMarkup:      replyMarkup,
+	}
+	c.messages = append(c.messages, msg)
+	return msg, []tdlib.TdMessage{
+		tdlib.NewUpdateNewMessage(copyMessage(msg)),
+		tdlib.NewUpdateChatLastMessage(c.chat.ID, copyMessage(msg), c.chat.Positions),
+	}
+}
+
+// editMessage changes message text and/or markup, must be called under lock
+func (s *Server) editMessage(c *chat, msg *tdlib.Message, text *string, replyMarkup tdlib.ReplyMarkup) []tdlib.TdMessage {
+	updates := make([]tdlib.TdMessage, 0, 2)
+	msg.EditDate = int32(time.Now().Unix())
+	if text != nil {
+		msg.Content = textContent(*text)
+		updates = append(updates, tdlib.NewUpdateMessageContent(c.chat.ID, msg.ID, msg.Content))
+	}
+	msg.ReplyMarkup = replyMarkup
+	updates = append(updates, tdlib.NewUpdateMessageEdited(c.chat.ID, msg.ID, msg.EditDate, replyMarkup))
+	return updates
+}
+
+// dispatch delivers updates to subscribed receivers
+func (s *Server) dispatch(updates []tdlib.TdMessage) {
+	s.dispatchMu.Lock()
+	defer s.dispatchMu.Unlock()
+	s.mu.Lock()
+	receivers := make([]*receiver, len(s.receivers))
+	copy(receivers, s.receivers)
+	s.mu.Unlock()
+
+	for _, update := range updates {
+		for _, r := range receivers {
+			if r.instance.MessageType() != update.MessageType() {
+				continue
+			}
+			msg := update
+			if !r.filter(&msg) {
+				continue
+			}
+			select {
+			case r.ch <- msg:
+			case <-r.done:
+			}
+		}
+	}
+}
+
+// message finds message by ID, must be called under lock
+func (c *chat) message(messageID int64) *tdlib.Message {
+	i := sort.Search(len(c.messages), func(i int) bool {
+		return c.messages[i].ID >= messageID
+	})
+	if i < len(c.messages) && c.messages[i].ID == messageID {
+		return c.messages[i]
+	}
+	return nil
+}
+
+// info returns copy of chat, must be called under lock
+func (c *chat) info() *tdlib.Chat {
+	info := c.chat
+	if len(c.messages) > 0 {
+		info.LastMessage = copyMessage(c.messages[len(c.messages)-1])
+	}
+	return &info
+}
+
+// textContent is content of plain text message
+func textContent(text string) tdlib.MessageContent {
+	return tdlib.NewMessageText(tdlib.NewFormattedText(text, nil), nil)
+}
+
+// copyMessage prevents consumers from altering server state
+func copyMessage(msg *tdlib.Message) *tdlib.Message {
+	m := *msg
+	return &m
+}
+
+var _ bottalker.TelegramBackend = (*Server)(nil)
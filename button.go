@@ -0,0 +1,176 @@
+package bottalker
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+)
+
+// CaptionMatchEnum is the way button caption is compared with command data
+type CaptionMatchEnum int
+
+// Enum to switch caption matching
+const (
+	CaptionExact  CaptionMatchEnum = iota // caption equals data
+	CaptionPrefix                         // caption starts with data
+	CaptionRegexp                         // caption matches data as regexp
+)
+
+// BotCommandButton pressing inline button found by caption, `BotCommand.Data` is the caption
+//
+// Button is looked up at trigger time in the newest chat messages,
+// so it keeps working when bot changes callback payloads or sends new keyboards
+type BotCommandButton struct {
+	Match CaptionMatchEnum // caption matching, exact by default
+	Depth int32            // how many latest messages are searched for button, 20 if zero
+	BotCommand
+}
+
+// captionMatcher checks button captions against command data, it isn't changed once built
+type captionMatcher struct {
+	match CaptionMatchEnum
+	data  string
+	re    *regexp.Regexp // compiled data for CaptionRegexp
+}
+
+// newCaptionMatcher compiles caption regexp if needed
+func newCaptionMatcher(match CaptionMatchEnum, data []byte) (*captionMatcher, error) {
+	cm := &captionMatcher{match: match, data: string(data)}
+	if match == CaptionRegexp {
+		re, err := regexp.Compile(cm.data)
+		if err != nil {
+			return nil, err
+		}
+		cm.re = re
+	}
+	return cm, nil
+}
+
+// matches checks button caption against command data
+func (cm *captionMatcher) matches(caption string) bool {
+	switch cm.match {
+	case CaptionPrefix:
+		return strings.HasPrefix(caption, cm.data)
+	case CaptionRegexp:
+		return cm.re.MatchString(caption)
+	}
+	return caption == cm.data
+}
+
+// setMatcher builds caption matcher of button and keyboard commands
+func (bc *BotCommand) setMatcher(match CaptionMatchEnum) error {
+	cm, err := newCaptionMatcher(match, bc.Data)
+	if err != nil {
+		return err
+	}
+	bc.Lock()
+	defer bc.Unlock()
+	bc.matcher = cm
+	return nil
+}
+
+// getMatcher returns caption matcher, it's immutable, so it's used without command lock
+func (bc *BotCommand) getMatcher() (*captionMatcher, error) {
+	bc.RLock()
+	defer bc.RUnlock()
+	if bc.matcher == nil {
+		return nil, fmt.Errorf("Caption matcher is not built")
+	}
+	return bc.matcher, nil
+}
+
+// latestMessages returns `depth` latest chat messages, 20 if `depth` isn't set, and depth used
+func (bc *BotCommand) latestMessages(depth int32) (*tdlib.Messages, int32, error) {
+	if depth <= 0 {
+		depth = 20
+	}
+	b := bc.getBot()
+	msgs, err := b.TelegramClient.Backend.GetChatHistory(b.ChatID, 0, 0, depth, false)
+	if err != nil {
+		return nil, depth, fmt.Errorf("GetChatHistory failed: %v", err)
+	}
+	return msgs, depth, nil
+}
+
+// findButton returns the newest message with matching callback button and button payload
+func (bcb *BotCommandButton) findButton() (*tdlib.Message, tdlib.CallbackQueryPayload, error) {
+	cm, err := bcb.getMatcher()
+	if err != nil {
+		return nil, nil, err
+	}
+	msgs, depth, err := bcb.latestMessages(bcb.Depth)
+	if err != nil {
+		return nil, nil, err
+	}
+	// history goes from the newest message to the oldest one
+	for i := range msgs.Messages {
+		msg := &msgs.Messages[i]
+		if _, ok := msg.ReplyMarkup.(*tdlib.ReplyMarkupInlineKeyboard); !ok {
+			continue
+		}
+		for _, btn := range GetMessageButtons(msg) {
+			if btn.IsCallback() && cm.matches(btn.Text) {
+				return msg, tdlib.NewCallbackQueryPayloadData(btn.Payload), nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("Button [%s] not found in %d latest messages", bcb.Data, depth)
+}
+
+// Trigger looking for button and pressing it
+func (bcb *BotCommandButton) Trigger() (*tdlib.Message, *BotError) {
+	m, _, bErr := bcb.TriggerWithAnswer()
+	return m, bErr
+}
+
+// TriggerWithAnswer looking for button, pressing it and returning bot's callback answer as well
+func (bcb *BotCommandButton) TriggerWithAnswer() (m *tdlib.Message, answer *tdlib.CallbackQueryAnswer, bErr *BotError) {
+	b, bErr := bcb.getInitializedBot(bcb)
+	if bErr != nil {
+		return nil, nil, bErr
+	}
+	start := b.startTrigger()
+	defer func() { b.observeTrigger(bcb, start, bErr) }()
+	msg, payload, err := bcb.findButton()
+	if err != nil {
+		return nil, nil, &BotError{
+			Err:         err,
+			ErrType:     BotErrError,
+			Bot:         b,
+			CommandType: bcb,
+		}
+	}
+	answer, err = b.TelegramClient.Backend.GetCallbackQueryAnswer(b.ChatID, msg.ID, payload)
+	if err != nil {
+		errType := BotErrError
+		if err.Error() == "timeout" {
+			errType = BotErrWarn
+		}
+		return nil, nil, &BotError{
+			Err:         fmt.Errorf("GetCallbackQueryAnswer [%s] failed: %s", bcb.Data, err),
+			ErrType:     errType,
+			Bot:         b,
+			CommandType: bcb,
+		}
+	}
+	m, err = b.TelegramClient.Backend.GetMessage(b.ChatID, msg.ID)
+	if err != nil {
+		return nil, nil, &BotError{
+			Err:         fmt.Errorf("GetMessage [%d] failed: %s", msg.ID, err),
+			ErrType:     BotErrError,
+			Bot:         b,
+			CommandType: bcb,
+		}
+	}
+	b.handleAnswer(bcb, m, answer, start)
+	return m, answer, nil
+}
+
+// TriggerAndWait pressing button and waiting for reply, which is either new bot message or edit of message with button
+func (bcb *BotCommandButton) TriggerAndWait(ctx context.Context, timeout time.Duration) (*Reply, *BotError) {
+	return bcb.getBot().triggerAndWait(ctx, bcb, timeout)
+}
@@ -0,0 +1,109 @@
+package bottalker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+func TestButtonCommand(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Old menu", bottalkertest.InlineKeyboard(
+		bottalkertest.Row(bottalkertest.CallbackButton("Refresh balance", "v1:refresh")),
+	))
+	qbot.Send("Menu", bottalkertest.InlineKeyboard(
+		bottalkertest.Row(
+			bottalkertest.CallbackButton("Help", "v2:help"),
+			bottalkertest.CallbackButton("Refresh balance", "v2:refresh"),
+		),
+	))
+	qbot.Send("Unrelated message", nil)
+	old := qbot.OnCallback("v1:refresh").Edit("Outdated", nil)
+	qbot.OnCallback("v2:refresh").Edit("BTC: 1.0", nil)
+
+	prefix := &bottalker.BotCommandButton{
+		Match: bottalker.CaptionPrefix,
+		BotCommand: bottalker.BotCommand{
+			Data:    []byte("Refresh"),
+			Passive: true,
+		},
+	}
+	missing := &bottalker.BotCommandButton{
+		Match: bottalker.CaptionRegexp,
+		BotCommand: bottalker.BotCommand{
+			Data:    []byte(`^Withdraw \d+$`),
+			Passive: true,
+		},
+	}
+	b := &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: time.Hour,
+		Commands:    []bottalker.BotCommandType{prefix, missing},
+	}
+	runBottalker(t, srv, b)
+
+	var reply string
+	waitFor(t, 5*time.Second, func() bool {
+		r, bErr := prefix.TriggerAndWait(context.Background(), time.Second)
+		if bErr != nil {
+			return false
+		}
+		reply = r.Text
+		return true
+	})
+	if reply != "BTC: 1.0" {
+		t.Errorf("unexpected reply: %s", reply)
+	}
+	if old.Hits() != 0 {
+		t.Error("button of older message was pressed")
+	}
+
+	if _, bErr := missing.Trigger(); bErr == nil || bErr.ErrType != bottalker.BotErrError {
+		t.Errorf("button not found error expected, got: %v", bErr)
+	}
+}
+
+func TestGetMessageButtonsInline(t *testing.T) {
+	msg := &tdlib.Message{
+		ReplyMarkup: bottalkertest.InlineKeyboard(
+			bottalkertest.Row(
+				bottalkertest.CallbackButton("Refresh", "/refresh"),
+				bottalkertest.URLButton("Site", "https://example.com"),
+			),
+			bottalkertest.Row(
+				bottalkertest.LoginURLButton("Login", "https://example.com/login", "Log in"),
+				bottalkertest.SwitchInlineButton("Share", "btc", true),
+			),
+			bottalkertest.Row(
+				bottalkertest.GameButton("Play"),
+				bottalkertest.BuyButton("Pay"),
+			),
+		),
+	}
+	expected := []bottalker.MessageButton{
+		{Kind: bottalker.ButtonCallback, Text: "Refresh", Payload: []byte("/refresh")},
+		{Kind: bottalker.ButtonURL, Text: "Site", URL: "https://example.com", Column: 1},
+		{Kind: bottalker.ButtonLoginURL, Text: "Login", URL: "https://example.com/login", ForwardText: "Log in", Row: 1},
+		{Kind: bottalker.ButtonSwitchInline, Text: "Share", Payload: []byte("btc"), SwitchInlineCurrentChat: true, Row: 1, Column: 1},
+		{Kind: bottalker.ButtonGame, Text: "Play", Row: 2},
+		{Kind: bottalker.ButtonBuy, Text: "Pay", Row: 2, Column: 1},
+	}
+	buttons := bottalker.GetMessageButtons(msg)
+	if len(buttons) != len(expected) {
+		t.Fatalf("unexpected buttons: %+v", buttons)
+	}
+	for i, btn := range buttons {
+		e := expected[i]
+		if btn.Kind != e.Kind || btn.Text != e.Text || string(btn.Payload) != string(e.Payload) || btn.URL != e.URL ||
+			btn.ForwardText != e.ForwardText || btn.SwitchInlineCurrentChat != e.SwitchInlineCurrentChat ||
+			btn.Row != e.Row || btn.Column != e.Column {
+			t.Errorf("button %d: expected %+v, got %+v", i, e, btn)
+		}
+	}
+}
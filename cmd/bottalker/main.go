@@ -0,0 +1,197 @@
+// Command bottalker runs bots described in config file and helps to prepare that config
+//
+// Usage:
+//
+//	bottalker login   [-config file | -id client]              authorize Telegram client
+//	bottalker chats   [-config file | -id client]              list chats with their IDs
+//	bottalker inspect [-config file | -id client] chat [msg]   print message, the latest one if msg is omitted
+//	bottalker run     -config file                             run bots
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/cdtj/bottalker-go"
+)
+
+const usage = `Usage: bottalker <command> [flags] [args]
+
+Commands:
+  login     authorize Telegram client
+  chats     list chats with their IDs
+  inspect   print message: inspect [flags] chat [msg]
+  run       run bots from config: run -config file
+
+Run 'bottalker <command> -h' for command flags.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "login":
+		err = login(os.Args[2:])
+	case "chats":
+		err = chats(os.Args[2:])
+	case "inspect":
+		err = inspect(os.Args[2:])
+	case "run":
+		err = run(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// clientFlags are flags shared by all commands
+type clientFlags struct {
+	config    string
+	id        string
+	verbosity int
+}
+
+func newFlagSet(name string, cf *clientFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.StringVar(&cf.config, "config", "", "path to YAML or JSON config `file`")
+	fs.StringVar(&cf.id, "id", "bottalker", "client `ID`, used if config is not specified")
+	fs.IntVar(&cf.verbosity, "verbosity", 1, "tdlib log verbosity `level`, used if config doesn't set it")
+	return fs
+}
+
+// bottalker builds Bottalker from config or from client ID only
+func (cf *clientFlags) bottalker() (*bottalker.Bottalker, error) {
+	bt := &bottalker.Bottalker{
+		TelegramClient: &bottalker.TelegramClient{
+			ID: cf.id,
+		},
+	}
+	if cf.config != "" {
+		var err error
+		bt, err = bottalker.LoadConfig(cf.config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if bt.TelegralLogLevel == 0 {
+		bt.TelegralLogLevel = cf.verbosity
+	}
+	return bt, nil
+}
+
+func login(args []string) error {
+	cf := &clientFlags{}
+	fs := newFlagSet("login", cf)
+	fs.Parse(args)
+
+	bt, err := cf.bottalker()
+	if err != nil {
+		return err
+	}
+	defer bt.Close()
+	return bt.Login()
+}
+
+func chats(args []string) error {
+	cf := &clientFlags{}
+	fs := newFlagSet("chats", cf)
+	fs.Parse(args)
+
+	bt, err := cf.bottalker()
+	if err != nil {
+		return err
+	}
+	defer bt.Close()
+	if err := bt.Connect(); err != nil {
+		return err
+	}
+	allChats := bt.TelegramClient.Chats()
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE")
+	for _, chat := range allChats {
+		fmt.Fprintf(tw, "%d\t%s\n", chat.ID, chat.Title)
+	}
+	return tw.Flush()
+}
+
+func inspect(args []string) error {
+	cf := &clientFlags{}
+	fs := newFlagSet("inspect", cf)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: bottalker inspect [flags] chat [msg]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	chatID, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid chat ID: %s", fs.Arg(0))
+	}
+	var msgID int64
+	if fs.NArg() == 2 {
+		msgID, err = strconv.ParseInt(fs.Arg(1), 10, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid message ID: %s", fs.Arg(1))
+		}
+	}
+
+	bt, err := cf.bottalker()
+	if err != nil {
+		return err
+	}
+	defer bt.Close()
+	if err := bt.Connect(); err != nil {
+		return err
+	}
+	msg, err := bt.TelegramClient.GetMessage(chatID, msgID)
+	if err != nil {
+		return err
+	}
+	bt.PrintMessage(msg)
+	return nil
+}
+
+func run(args []string) error {
+	cf := &clientFlags{}
+	fs := newFlagSet("run", cf)
+	fs.Parse(args)
+	if cf.config == "" {
+		return fmt.Errorf("-config is required to run bots")
+	}
+
+	bt, err := cf.bottalker()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ch
+		cancel()
+	}()
+	return bt.Run(ctx)
+}
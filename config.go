@@ -0,0 +1,617 @@
+package bottalker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is file representation of Bottalker, use `LoadConfig` to build Bottalker from file
+//
+// Example:
+//
+//	control_addr: 127.0.0.1:8080
+//	control_token: secret
+//	metrics_addr: :9090
+//	state_file: ./state/bottalker.json
+//	talker_log_format: json
+//	talker_log_level: debug
+//	client:
+//	  id: checker
+//	  proxies:
+//	    - {server: 127.0.0.1, port: 9050, enable: true, type: socks5}
+//	bots:
+//	  - label: QBot
+//	    chat_id: 600120108
+//	    check_interval: 30s
+//	    commands:
+//	      - {type: payload, data: /bal_btc}
+//	      - {type: chat, data: /start, passive: true}
+//	      - {type: chat, data: /report, schedule: {at: ['09:00'], timezone: Europe/Berlin}}
+//	      - {type: chat, data: /ping, schedule: {cron: '* * * * *'}}
+//	      - {type: chat, data: /withdraw, repeat: until, until: 'Withdrawal confirmed'}
+//	    cooldown_rules:
+//	      - {match: 'wait (?P<minutes>\d+) minutes', duration: 5m}
+type Config struct {
+	Client           ClientConfig `json:"client" yaml:"client"`                         // Telegram client
+	Bots             []BotConfig  `json:"bots" yaml:"bots"`                             // bots to talk with
+	TelegramLog      *string      `json:"telegram_log" yaml:"telegram_log"`             // see `Bottalker.TelegramLog`
+	TelegramLogLevel int          `json:"telegram_log_level" yaml:"telegram_log_level"` // see `Bottalker.TelegralLogLevel`
+	TalkerLog        *string      `json:"talker_log" yaml:"talker_log"`                 // see `Bottalker.TalkerLog`
+	TalkerLogFormat  string       `json:"talker_log_format" yaml:"talker_log_format"`   // see `Bottalker.TalkerLogFormat`
+	TalkerLogLevel   string       `json:"talker_log_level" yaml:"talker_log_level"`     // see `Bottalker.TalkerLogLevel`
+	ControlAddr      string       `json:"control_addr" yaml:"control_addr"`             // see `Bottalker.ControlAddr`
+	ControlToken     string       `json:"control_token" yaml:"control_token"`           // see `Bottalker.ControlToken`
+	MetricsAddr      string       `json:"metrics_addr" yaml:"metrics_addr"`             // see `Bottalker.MetricsAddr`
+	StateFile        string       `json:"state_file" yaml:"state_file"`                 // path of FileStore, see `Bottalker.Store`
+}
+
+// ClientConfig is file representation of TelegramClient
+type ClientConfig struct {
+	ID                 string        `json:"id" yaml:"id"`                                     // see `TelegramClient.ID`
+	APIID              string        `json:"api_id" yaml:"api_id"`                             // tdlib `APIID`, default one is used if empty
+	APIHash            string        `json:"api_hash" yaml:"api_hash"`                         // tdlib `APIHash`, default one is used if empty
+	SystemLanguageCode string        `json:"system_language_code" yaml:"system_language_code"` // tdlib `SystemLanguageCode`
+	DeviceModel        string        `json:"device_model" yaml:"device_model"`                 // tdlib `DeviceModel`
+	DatabaseDirectory  string        `json:"database_directory" yaml:"database_directory"`     // tdlib `DatabaseDirectory`
+	FileDirectory      string        `json:"file_directory" yaml:"file_directory"`             // tdlib `FileDirectory`
+	UseTestDataCenter  bool          `json:"use_test_dc" yaml:"use_test_dc"`                   // tdlib `UseTestDataCenter`
+	Proxies            []ProxyConfig `json:"proxies" yaml:"proxies"`                           // see `TelegramClient.Proxies`
+}
+
+// ProxyConfig is file representation of ClientProxy
+type ProxyConfig struct {
+	Server   string `json:"server" yaml:"server"`       // server dns
+	Port     int32  `json:"port" yaml:"port"`           // port
+	Enable   bool   `json:"enable" yaml:"enable"`       // see `ClientProxy.Enable`
+	Type     string `json:"type" yaml:"type"`           // one of: `socks5`, `http`, `mtproto`
+	Username string `json:"username" yaml:"username"`   // socks5 and http only
+	Password string `json:"password" yaml:"password"`   // socks5 and http only
+	HTTPOnly bool   `json:"http_only" yaml:"http_only"` // http only; see `tdlib.ProxyTypeHttp`
+	Secret   string `json:"secret" yaml:"secret"`       // mtproto only
+}
+
+// BotConfig is file representation of Bot
+type BotConfig struct {
+	Label          string           `json:"label" yaml:"label"`                     // see `Bot.Label`
+	ChatID         int64            `json:"chat_id" yaml:"chat_id"`                 // see `Bot.ChatID`
+	CheckInterval  ConfigDuration   `json:"check_interval" yaml:"check_interval"`   // see `Bot.ChkInterval`
+	ReportInterval ConfigDuration   `json:"report_interval" yaml:"report_interval"` // see `Bot.RepInterval`
+	NotifyID       int64            `json:"notify_id" yaml:"notify_id"`             // see `Bot.NotifyID`
+	LogID          int64            `json:"log_id" yaml:"log_id"`                   // see `Bot.LogID`
+	Commands       []CommandConfig  `json:"commands" yaml:"commands"`               // see `Bot.Commands`
+	CooldownRules  []CooldownConfig `json:"cooldown_rules" yaml:"cooldown_rules"`   // see `Bot.CooldownRules`
+	Retry          *RetryConfig     `json:"retry" yaml:"retry"`                     // see `Bot.RetryPolicy`
+	Reactions      []ReactionConfig `json:"reactions" yaml:"reactions"`             // see `Bot.Reactions`
+}
+
+// ReactionConfig is file representation of ReactionRule
+type ReactionConfig struct {
+	Match   string `json:"match" yaml:"match"`     // regexp, see `ReactionRule.Match`
+	Button  string `json:"button" yaml:"button"`   // see `ReactionRule.Button`
+	Content string `json:"content" yaml:"content"` // tdlib content type like `messagePhoto`, see `ReactionRule.Content`
+	Trigger string `json:"trigger" yaml:"trigger"` // data of one of bot commands, see `ReactionRule.Trigger`
+	Press   string `json:"press" yaml:"press"`     // see `ReactionRule.Press`
+	Send    string `json:"send" yaml:"send"`       // see `ReactionRule.Send`
+
+	Cooldown ConfigDuration `json:"cooldown" yaml:"cooldown"` // see `ReactionRule.Cooldown`
+}
+
+// RetryConfig is file representation of RetryPolicy, behaviours are `skip`, `backoff` or `stop`
+type RetryConfig struct {
+	MaxAttempts  int            `json:"max_attempts" yaml:"max_attempts"`   // see `RetryPolicy.MaxAttempts`
+	InitialDelay ConfigDuration `json:"initial_delay" yaml:"initial_delay"` // see `RetryPolicy.InitialDelay`
+	MaxDelay     ConfigDuration `json:"max_delay" yaml:"max_delay"`         // see `RetryPolicy.MaxDelay`
+	Multiplier   float64        `json:"multiplier" yaml:"multiplier"`       // see `RetryPolicy.Multiplier`
+	Jitter       float64        `json:"jitter" yaml:"jitter"`               // see `RetryPolicy.Jitter`
+	OnWarn       string         `json:"on_warn" yaml:"on_warn"`             // behaviour on warnings, `skip` by default
+	OnError      string         `json:"on_error" yaml:"on_error"`           // behaviour on errors, `backoff` by default
+	OnFatal      string         `json:"on_fatal" yaml:"on_fatal"`           // behaviour on fatal errors, `backoff` by default
+}
+
+// CooldownConfig is file representation of CooldownRule
+type CooldownConfig struct {
+	Match    string         `json:"match" yaml:"match"`       // regexp, see `CooldownRule.Match`
+	Duration ConfigDuration `json:"duration" yaml:"duration"` // see `CooldownRule.Duration`
+}
+
+// CommandConfig is file representation of BotCommandType
+type CommandConfig struct {
+	Type     string          `json:"type" yaml:"type"`         // one of: `chat` for BotCommandChat, `payload` for BotCommandPayload, `button` for BotCommandButton, `keyboard` for BotCommandKeyboard
+	Data     string          `json:"data" yaml:"data"`         // see `BotCommand.Data`
+	Passive  bool            `json:"passive" yaml:"passive"`   // see `BotCommand.Passive`
+	MsgID    int64           `json:"msg_id" yaml:"msg_id"`     // payload only; see `BotCommandPayload.MsgID`
+	Match    string          `json:"match" yaml:"match"`       // button and keyboard only; one of: `exact` (default), `prefix`, `regexp`
+	Depth    int32           `json:"depth" yaml:"depth"`       // button and keyboard only; see `BotCommandButton.Depth`
+	Extract  []ExtractConfig `json:"extract" yaml:"extract"`   // see `BotCommand.Extract`
+	Schedule *ScheduleConfig `json:"schedule" yaml:"schedule"` // see `BotCommand.Schedule`
+	Repeat   string          `json:"repeat" yaml:"repeat"`     // one of: `forever` (default), `once`, `times`, `until`
+	Times    int             `json:"times" yaml:"times"`       // repeat `times` only; see `BotCommand.RepeatTimes`
+	Until    string          `json:"until" yaml:"until"`       // repeat `until` only; regexp, see `BotCommand.RepeatUntil`
+}
+
+// ScheduleConfig is file representation of Schedule, exactly one of `cron`, `every` or `at` should be set
+type ScheduleConfig struct {
+	Cron     string         `json:"cron" yaml:"cron"`         // cron expression, see `ParseCron`
+	Every    ConfigDuration `json:"every" yaml:"every"`       // fixed interval, see `Every`
+	At       []string       `json:"at" yaml:"at"`             // times of day like `09:00`, see `DailyAt`
+	Timezone string         `json:"timezone" yaml:"timezone"` // IANA timezone like `Europe/Berlin` for `cron` and `at`, local by default
+}
+
+// ExtractConfig is file representation of ExtractRule
+type ExtractConfig struct {
+	Name      string `json:"name" yaml:"name"`           // see `ExtractRule.Name`
+	Match     string `json:"match" yaml:"match"`         // regexp, see `ExtractRule.Match`
+	Line      int    `json:"line" yaml:"line"`           // see `ExtractRule.Line`
+	Field     int    `json:"field" yaml:"field"`         // see `ExtractRule.Field`
+	Separator string `json:"separator" yaml:"separator"` // see `ExtractRule.Separator`
+}
+
+// Command types used in CommandConfig
+const (
+	CommandTypeChat     = "chat"
+	CommandTypePayload  = "payload"
+	CommandTypeButton   = "button"
+	CommandTypeKeyboard = "keyboard"
+)
+
+// ConfigDuration is time.Duration which can be read from string like `30s` or `1h30m`
+type ConfigDuration time.Duration
+
+// UnmarshalJSON parses duration from JSON string
+func (d *ConfigDuration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration should be a string: %s", data)
+	}
+	return d.parse(s)
+}
+
+// UnmarshalYAML parses duration from YAML string
+func (d *ConfigDuration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return d.parse(s)
+}
+
+func (d *ConfigDuration) parse(s string) error {
+	if s == "" {
+		*d = 0
+		return nil
+	}
+	pd, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = ConfigDuration(pd)
+	return nil
+}
+
+// LoadConfig reads YAML (`.yml`, `.yaml`) or JSON (`.json`) config and builds Bottalker from it
+func LoadConfig(path string) (*Bottalker, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read config: %v", err)
+	}
+
+	cfg := &Config{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		err = yaml.UnmarshalStrict(data, cfg)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	default:
+		return nil, fmt.Errorf("Unknown config format: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse config %s: %v", path, err)
+	}
+
+	return cfg.Build()
+}
+
+// Build creates Bottalker with all its bots and commands
+func (cfg *Config) Build() (*Bottalker, error) {
+	if cfg.Client.ID == "" {
+		return nil, fmt.Errorf("Invalid config: client.id is required")
+	}
+
+	tc := &TelegramClient{
+		ID: cfg.Client.ID,
+		Config: &tdlib.Config{
+			APIID:              cfg.Client.APIID,
+			APIHash:            cfg.Client.APIHash,
+			SystemLanguageCode: cfg.Client.SystemLanguageCode,
+			DeviceModel:        cfg.Client.DeviceModel,
+			DatabaseDirectory:  cfg.Client.DatabaseDirectory,
+			FileDirectory:      cfg.Client.FileDirectory,
+			UseTestDataCenter:  cfg.Client.UseTestDataCenter,
+		},
+	}
+	for i, pc := range cfg.Client.Proxies {
+		proxy, err := pc.build()
+		if err != nil {
+			return nil, fmt.Errorf("Invalid config: client.proxies[%d]: %v", i, err)
+		}
+		tc.Proxies = append(tc.Proxies, proxy)
+	}
+
+	bt := &Bottalker{
+		TelegramClient:   tc,
+		TelegramLog:      cfg.TelegramLog,
+		TelegralLogLevel: cfg.TelegramLogLevel,
+		TalkerLog:        cfg.TalkerLog,
+		TalkerLogFormat:  cfg.TalkerLogFormat,
+		TalkerLogLevel:   cfg.TalkerLogLevel,
+		ControlAddr:      cfg.ControlAddr,
+		ControlToken:     cfg.ControlToken,
+		MetricsAddr:      cfg.MetricsAddr,
+	}
+	if _, err := bt.newLogger(ioutil.Discard); err != nil {
+		return nil, fmt.Errorf("Invalid config: %v", err)
+	}
+	if cfg.StateFile != "" {
+		store, err := NewFileStore(cfg.StateFile)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid config: %v", err)
+		}
+		bt.Store = store
+	}
+	for i, bc := range cfg.Bots {
+		b, err := bc.build()
+		if err != nil {
+			return nil, fmt.Errorf("Invalid config: bots[%d]: %v", i, err)
+		}
+		bt.Bots = append(bt.Bots, b)
+	}
+	return bt, nil
+}
+
+func (pc *ProxyConfig) build() (*ClientProxy, error) {
+	if pc.Server == "" || pc.Port == 0 {
+		return nil, fmt.Errorf("server and port are required")
+	}
+
+	var typeParam tdlib.ProxyType
+	switch strings.ToLower(pc.Type) {
+	case "socks5":
+		typeParam = tdlib.NewProxyTypeSocks5(pc.Username, pc.Password)
+	case "http":
+		typeParam = tdlib.NewProxyTypeHttp(pc.Username, pc.Password, pc.HTTPOnly)
+	case "mtproto":
+		typeParam = tdlib.NewProxyTypeMtproto(pc.Secret)
+	default:
+		return nil, fmt.Errorf("unknown proxy type: %q", pc.Type)
+	}
+
+	return &ClientProxy{
+		Server:    pc.Server,
+		Port:      pc.Port,
+		Enable:    pc.Enable,
+		TypeParam: typeParam,
+	}, nil
+}
+
+func (bc *BotConfig) build() (*Bot, error) {
+	if bc.ChatID == 0 {
+		return nil, fmt.Errorf("chat_id is required")
+	}
+	if bc.CheckInterval < 0 {
+		return nil, fmt.Errorf("check_interval should be positive")
+	}
+
+	b := &Bot{
+		Label:       bc.Label,
+		ChatID:      bc.ChatID,
+		ChkInterval: time.Duration(bc.CheckInterval),
+		NotifyID:    bc.NotifyID,
+		LogID:       bc.LogID,
+		RepInterval: time.Duration(bc.ReportInterval),
+	}
+	for i, cc := range bc.Commands {
+		bct, err := cc.build()
+		if err != nil {
+			return nil, fmt.Errorf("commands[%d]: %v", i, err)
+		}
+		b.Commands = append(b.Commands, bct)
+		if b.ChkInterval == 0 && !cc.Passive && cc.Schedule == nil {
+			return nil, fmt.Errorf("check_interval is required by commands[%d] which has no schedule", i)
+		}
+	}
+	if bc.Retry != nil {
+		rp, err := bc.Retry.build()
+		if err != nil {
+			return nil, fmt.Errorf("retry: %v", err)
+		}
+		b.RetryPolicy = rp
+	}
+	for i, rc := range bc.Reactions {
+		rr, err := rc.build(b.Commands)
+		if err != nil {
+			return nil, fmt.Errorf("reactions[%d]: %v", i, err)
+		}
+		b.Reactions = append(b.Reactions, rr)
+	}
+	for i, cc := range bc.CooldownRules {
+		re, err := regexp.Compile(cc.Match)
+		if err != nil {
+			return nil, fmt.Errorf("cooldown_rules[%d]: %v", i, err)
+		}
+		b.CooldownRules = append(b.CooldownRules, &CooldownRule{
+			Match:    re,
+			Duration: time.Duration(cc.Duration),
+		})
+	}
+	return b, nil
+}
+
+func (rc *RetryConfig) build() (*RetryPolicy, error) {
+	if rc.MaxAttempts < 1 {
+		return nil, fmt.Errorf("max_attempts should be positive")
+	}
+
+	rp := &RetryPolicy{
+		MaxAttempts:  rc.MaxAttempts,
+		InitialDelay: time.Duration(rc.InitialDelay),
+		MaxDelay:     time.Duration(rc.MaxDelay),
+		Multiplier:   rc.Multiplier,
+		Jitter:       rc.Jitter,
+		Behaviour:    make(map[BotErrorTypeEnum]RetryBehaviour),
+	}
+	for errType, name := range map[BotErrorTypeEnum]string{
+		BotErrWarn:  rc.OnWarn,
+		BotErrError: rc.OnError,
+		BotErrFatal: rc.OnFatal,
+	} {
+		switch strings.ToLower(name) {
+		case "":
+			rp.Behaviour[errType] = DefaultRetryPolicy.behaviour(errType)
+		case "skip":
+			rp.Behaviour[errType] = RetrySkip
+		case "backoff":
+			rp.Behaviour[errType] = RetryBackoff
+		case "stop":
+			rp.Behaviour[errType] = RetryStop
+		default:
+			return nil, fmt.Errorf("unknown behaviour on %s: %s", errType, name)
+		}
+	}
+	return rp, nil
+}
+
+// build creates ReactionRule, `Trigger` is looked up in `commands` by data
+func (rc *ReactionConfig) build(commands []BotCommandType) (*ReactionRule, error) {
+	rr := &ReactionRule{
+		Button:  rc.Button,
+		Content: tdlib.MessageContentEnum(rc.Content),
+		Press:   rc.Press,
+		Send:    rc.Send,
+
+		Cooldown: time.Duration(rc.Cooldown),
+	}
+	if rc.Match != "" {
+		re, err := regexp.Compile(rc.Match)
+		if err != nil {
+			return nil, err
+		}
+		rr.Match = re
+	}
+	if rc.Trigger != "" {
+		for _, bct := range commands {
+			if string(bct.getData()) == rc.Trigger {
+				rr.Trigger = bct
+				break
+			}
+		}
+		if rr.Trigger == nil {
+			return nil, fmt.Errorf("trigger: unknown command %q", rc.Trigger)
+		}
+	}
+	if rr.Trigger == nil && rr.Press == "" && rr.Send == "" {
+		return nil, fmt.Errorf("one of trigger, press or send is required")
+	}
+	return rr, nil
+}
+
+func (cc *CommandConfig) build() (BotCommandType, error) {
+	if cc.Data == "" {
+		return nil, fmt.Errorf("data is required")
+	}
+	var schedule Schedule
+	if cc.Schedule != nil {
+		var err error
+		if schedule, err = cc.Schedule.build(); err != nil {
+			return nil, fmt.Errorf("schedule: %v", err)
+		}
+	}
+	repeat, until, err := cc.buildRepeat()
+	if err != nil {
+		return nil, err
+	}
+	var rules []*ExtractRule
+	for i, ec := range cc.Extract {
+		er, err := ec.build()
+		if err != nil {
+			return nil, fmt.Errorf("extract[%d]: %v", i, err)
+		}
+		rules = append(rules, er)
+	}
+
+	switch strings.ToLower(cc.Type) {
+	case CommandTypeChat:
+		return &BotCommandChat{
+			BotCommand: BotCommand{
+				Data:        []byte(cc.Data),
+				Passive:     cc.Passive,
+				Schedule:    schedule,
+				Repeat:      repeat,
+				RepeatTimes: cc.Times,
+				RepeatUntil: until,
+				Extract:     rules,
+			},
+		}, nil
+	case CommandTypePayload:
+		return &BotCommandPayload{
+			MsgID: cc.MsgID,
+			BotCommand: BotCommand{
+				Data:        []byte(cc.Data),
+				Passive:     cc.Passive,
+				Schedule:    schedule,
+				Repeat:      repeat,
+				RepeatTimes: cc.Times,
+				RepeatUntil: until,
+				Extract:     rules,
+			},
+		}, nil
+	case CommandTypeButton:
+		bcb := &BotCommandButton{
+			Depth: cc.Depth,
+			BotCommand: BotCommand{
+				Data:        []byte(cc.Data),
+				Passive:     cc.Passive,
+				Schedule:    schedule,
+				Repeat:      repeat,
+				RepeatTimes: cc.Times,
+				RepeatUntil: until,
+				Extract:     rules,
+			},
+		}
+		match, err := cc.buildMatch()
+		if err != nil {
+			return nil, err
+		}
+		bcb.Match = match
+		return bcb, nil
+	case CommandTypeKeyboard:
+		bck := &BotCommandKeyboard{
+			Depth: cc.Depth,
+			BotCommand: BotCommand{
+				Data:        []byte(cc.Data),
+				Passive:     cc.Passive,
+				Schedule:    schedule,
+				Repeat:      repeat,
+				RepeatTimes: cc.Times,
+				RepeatUntil: until,
+				Extract:     rules,
+			},
+		}
+		match, err := cc.buildMatch()
+		if err != nil {
+			return nil, err
+		}
+		bck.Match = match
+		return bck, nil
+	}
+	return nil, fmt.Errorf("unknown command type: %q", cc.Type)
+}
+
+func (sc *ScheduleConfig) build() (Schedule, error) {
+	loc := time.Local
+	if sc.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(sc.Timezone); err != nil {
+			return nil, err
+		}
+	}
+
+	set := 0
+	for _, ok := range []bool{sc.Cron != "", sc.Every != 0, len(sc.At) > 0} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("exactly one of cron, every or at is required")
+	}
+
+	switch {
+	case sc.Cron != "":
+		return ParseCron(sc.Cron, loc)
+	case len(sc.At) > 0:
+		return DailyAt(loc, sc.At...)
+	}
+	if sc.Every < 0 {
+		return nil, fmt.Errorf("every should be positive")
+	}
+	return Every(time.Duration(sc.Every)), nil
+}
+
+func (ec *ExtractConfig) build() (*ExtractRule, error) {
+	er := &ExtractRule{
+		Name:      ec.Name,
+		Line:      ec.Line,
+		Field:     ec.Field,
+		Separator: ec.Separator,
+	}
+	if ec.Match != "" {
+		re, err := regexp.Compile(ec.Match)
+		if err != nil {
+			return nil, err
+		}
+		er.Match = re
+	}
+	if er.Name != "" {
+		return er, nil
+	}
+	if er.Match != nil {
+		for _, name := range er.Match.SubexpNames() {
+			if name != "" {
+				return er, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("name is required unless match has named groups")
+}
+
+// buildMatch parses caption matching of button and keyboard commands
+func (cc *CommandConfig) buildMatch() (CaptionMatchEnum, error) {
+	switch strings.ToLower(cc.Match) {
+	case "", "exact":
+		return CaptionExact, nil
+	case "prefix":
+		return CaptionPrefix, nil
+	case "regexp":
+		if _, err := regexp.Compile(cc.Data); err != nil {
+			return 0, fmt.Errorf("data: %v", err)
+		}
+		return CaptionRegexp, nil
+	}
+	return 0, fmt.Errorf("unknown match: %q", cc.Match)
+}
+
+// buildRepeat parses command repetition mode and its condition
+func (cc *CommandConfig) buildRepeat() (RepeatModeEnum, *regexp.Regexp, error) {
+	switch strings.ToLower(cc.Repeat) {
+	case "", "forever":
+		return RepeatForever, nil, nil
+	case "once":
+		return RepeatOnce, nil, nil
+	case "times":
+		if cc.Times < 1 {
+			return 0, nil, fmt.Errorf("times should be positive")
+		}
+		return RepeatTimes, nil, nil
+	case "until":
+		if cc.Until == "" {
+			return 0, nil, fmt.Errorf("until is required")
+		}
+		re, err := regexp.Compile(cc.Until)
+		if err != nil {
+			return 0, nil, fmt.Errorf("until: %v", err)
+		}
+		return RepeatUntil, re, nil
+	}
+	return 0, nil, fmt.Errorf("unknown repeat: %q", cc.Repeat)
+}
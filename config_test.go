@@ -0,0 +1,152 @@
+package bottalker_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+	"github.com/cdtj/bottalker-go"
+)
+
+const yamlConfig = `
+control_addr: 127.0.0.1:8080
+control_token: secret
+client:
+  id: checker
+  proxies:
+    - {server: 127.0.0.1, port: 9050, enable: true, type: socks5}
+bots:
+  - label: QBot
+    chat_id: 600120108
+    check_interval: 30s
+    retry: {max_attempts: 5, initial_delay: 2s, on_error: stop}
+    commands:
+      - type: payload
+        data: /bal_btc
+        msg_id: 42
+        extract:
+          - {match: 'BTC: (?P<btc>[\d.]+)'}
+          - {name: updated, line: -1}
+      - {type: chat, data: /start, passive: true, repeat: times, times: 2}
+      - {type: chat, data: /report, schedule: {at: ['09:00'], timezone: UTC}}
+    reactions:
+      - {button: Refresh, trigger: /start}
+`
+
+const jsonConfig = `{
+  "client": {
+    "id": "checker",
+    "proxies": [{"server": "127.0.0.1", "port": 443, "type": "mtproto", "secret": "abc"}]
+  },
+  "bots": [{
+    "label": "QBot",
+    "chat_id": 600120108,
+    "check_interval": "1m30s",
+    "commands": [{"type": "chat", "data": "/start"}]
+  }]
+}`
+
+func writeConfig(t *testing.T, name string, data string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigYAML(t *testing.T) {
+	bt, err := bottalker.LoadConfig(writeConfig(t, "bottalker.yml", yamlConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bt.ControlAddr != "127.0.0.1:8080" {
+		t.Errorf("unexpected control address: %s", bt.ControlAddr)
+	}
+	if bt.ControlToken != "secret" {
+		t.Errorf("unexpected control token: %s", bt.ControlToken)
+	}
+	if bt.TelegramClient.ID != "checker" {
+		t.Errorf("unexpected client id: %s", bt.TelegramClient.ID)
+	}
+	if len(bt.TelegramClient.Proxies) != 1 {
+		t.Fatalf("unexpected proxies: %+v", bt.TelegramClient.Proxies)
+	}
+	if _, ok := bt.TelegramClient.Proxies[0].TypeParam.(*tdlib.ProxyTypeSocks5); !ok {
+		t.Errorf("unexpected proxy type: %T", bt.TelegramClient.Proxies[0].TypeParam)
+	}
+	if len(bt.Bots) != 1 || bt.Bots[0].ChkInterval != 30*time.Second {
+		t.Fatalf("unexpected bots: %+v", bt.Bots)
+	}
+	rp := bt.Bots[0].RetryPolicy
+	if rp == nil || rp.MaxAttempts != 5 || rp.InitialDelay != 2*time.Second {
+		t.Fatalf("unexpected retry policy: %+v", rp)
+	}
+	if rp.Behaviour[bottalker.BotErrError] != bottalker.RetryStop || rp.Behaviour[bottalker.BotErrFatal] != bottalker.RetryBackoff {
+		t.Errorf("unexpected retry behaviour: %+v", rp.Behaviour)
+	}
+	commands := bt.Bots[0].Commands
+	if len(commands) != 3 {
+		t.Fatalf("unexpected commands: %+v", commands)
+	}
+	bcp, ok := commands[0].(*bottalker.BotCommandPayload)
+	if !ok || bcp.MsgID != 42 || string(bcp.Data) != "/bal_btc" {
+		t.Errorf("unexpected payload command: %+v", commands[0])
+	}
+	if reactions := bt.Bots[0].Reactions; len(reactions) != 1 || reactions[0].Trigger != commands[1] {
+		t.Errorf("unexpected reactions: %+v", reactions)
+	}
+	if len(bcp.Extract) != 2 || bcp.Extract[0].Match == nil || bcp.Extract[1].Line != -1 {
+		t.Errorf("unexpected extract rules: %+v", bcp.Extract)
+	}
+	bcc, ok := commands[1].(*bottalker.BotCommandChat)
+	if !ok || !bcc.Passive || string(bcc.Data) != "/start" || bcc.Repeat != bottalker.RepeatTimes || bcc.RepeatTimes != 2 {
+		t.Errorf("unexpected chat command: %+v", commands[1])
+	}
+	report := commands[2].(*bottalker.BotCommandChat)
+	from := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	if report.Schedule == nil || !report.Schedule.Next(from).Equal(time.Date(2021, 3, 2, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected schedule: %+v", report.Schedule)
+	}
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	bt, err := bottalker.LoadConfig(writeConfig(t, "bottalker.json", jsonConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bt.TelegramClient.Proxies[0].TypeParam.(*tdlib.ProxyTypeMtproto); !ok {
+		t.Errorf("unexpected proxy type: %T", bt.TelegramClient.Proxies[0].TypeParam)
+	}
+	if bt.Bots[0].ChkInterval != 90*time.Second {
+		t.Errorf("unexpected check interval: %v", bt.Bots[0].ChkInterval)
+	}
+	if _, ok := bt.Bots[0].Commands[0].(*bottalker.BotCommandChat); !ok {
+		t.Errorf("unexpected command type: %T", bt.Bots[0].Commands[0])
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"no_client.yml":    "bots: []",
+		"bad_duration.yml": "client: {id: c}\nbots: [{chat_id: 1, check_interval: often}]",
+		"bad_command.yml":  "client: {id: c}\nbots: [{chat_id: 1, check_interval: 1s, commands: [{type: voice, data: x}]}]",
+		"bad_proxy.json":   `{"client": {"id": "c", "proxies": [{"server": "s", "port": 1, "type": "vpn"}]}}`,
+		"bad_extract.yml":  "client: {id: c}\nbots: [{chat_id: 1, check_interval: 1s, commands: [{type: chat, data: x, extract: [{match: 'BTC'}]}]}]",
+		"bad_reaction.yml": "client: {id: c}\nbots: [{chat_id: 1, check_interval: 1s, reactions: [{trigger: /missing}]}]",
+		"bad_retry.yml":    "client: {id: c}\nbots: [{chat_id: 1, check_interval: 1s, retry: {max_attempts: 2, on_warn: panic}}]",
+		"no_interval.yml":  "client: {id: c}\nbots: [{chat_id: 1, commands: [{type: chat, data: x}]}]",
+		"bad_schedule.yml": "client: {id: c}\nbots: [{chat_id: 1, commands: [{type: chat, data: x, schedule: {cron: '61 * * * *'}}]}]",
+		"two_schedule.yml": "client: {id: c}\nbots: [{chat_id: 1, commands: [{type: chat, data: x, schedule: {every: 1m, at: ['09:00']}}]}]",
+		"bad_timezone.yml": "client: {id: c}\nbots: [{chat_id: 1, commands: [{type: chat, data: x, schedule: {at: ['09:00'], timezone: Mars/Base}}]}]",
+		"bad_repeat.yml":   "client: {id: c}\nbots: [{chat_id: 1, check_interval: 1s, commands: [{type: chat, data: x, repeat: twice}]}]",
+		"bad_times.yml":    "client: {id: c}\nbots: [{chat_id: 1, check_interval: 1s, commands: [{type: chat, data: x, repeat: times}]}]",
+		"bad_until.yml":    "client: {id: c}\nbots: [{chat_id: 1, check_interval: 1s, commands: [{type: chat, data: x, repeat: until, until: '('}]}]",
+		"config.toml":      "",
+	} {
+		if _, err := bottalker.LoadConfig(writeConfig(t, name, data)); err == nil {
+			t.Errorf("%s: error expected", name)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package bottalker
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+)
+
+// ControlHandler returns HTTP handler of control API, `Run` serves it on `ControlAddr`
+//
+// Endpoints, all responses are JSON, errors are returned as `{"error": "..."}`:
+//
+//	GET  /bots                                  list bots with their commands state
+//	GET  /bots/{label}                          show bot
+//	POST /bots/{label}/pause                    pause bot, see `Bot.Pause`
+//	POST /bots/{label}/resume                   resume bot
+//	POST /bots/{label}/cooldown                 set cooldown from body `{"duration": "5m"}`, zero duration resumes bot
+//	POST /bots/{label}/commands/{index}/trigger trigger command, `?wait=10s` waits for bot reply, `?force=true` triggers paused bot
+//	POST /bots/{label}/commands/{index}/start   start command, see `BotCommand.Start`
+//	POST /bots/{label}/commands/{index}/stop    stop command
+//
+// Requests must have `Authorization: Bearer <ControlToken>` header if `ControlToken` is set.
+//
+// Triggers are counted, retried and reported like scheduled ones, paused or cooling down bot responds with 409
+// unless trigger is forced, bot which isn't initialized yet responds with 503 and Telegram errors with 502.
+//
+// Commands are triggered from request goroutine, concurrently with bot goroutine: bot and command state
+// is guarded by their locks, but triggers aren't serialized, so bot may receive manual trigger right after
+// scheduled one and replies are attributed to the last triggered command.
+func (bt *Bottalker) ControlHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !bt.authorizeControl(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeControlError(w, http.StatusUnauthorized, fmt.Errorf("Unauthorized"))
+			return
+		}
+		bt.serveControl(w, r)
+	})
+}
+
+// authorizeControl checks request bearer token, every request is authorized if `ControlToken` is empty
+func (bt *Bottalker) authorizeControl(r *http.Request) bool {
+	if bt.ControlToken == "" {
+		return true
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth[len("Bearer "):]), []byte(bt.ControlToken)) == 1
+}
+
+// checkControlAddr refuses to serve control API without `ControlToken` on address reachable from other hosts
+func (bt *Bottalker) checkControlAddr() error {
+	if bt.ControlAddr == "" || bt.ControlToken != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(bt.ControlAddr)
+	if err != nil {
+		return fmt.Errorf("Unable to start control API: %v", err)
+	}
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return nil
+	}
+	return fmt.Errorf("Unable to start control API: ControlToken is required to serve it on non-loopback address %s", bt.ControlAddr)
+}
+
+// botView is JSON representation of BotStatus
+type botView struct {
+	Label         string        `json:"label"`
+	ChatID        int64         `json:"chat_id"`
+	Paused        bool          `json:"paused"`
+	Cooldown      string        `json:"cooldown,omitempty"`       // remaining cooldown like `4m59s`
+	CooldownUntil *time.Time    `json:"cooldown_until,omitempty"` // end of cooldown
+	Commands      []commandView `json:"commands"`
+}
+
+// commandView is JSON representation of CommandStatus, commands are addressed by `Index` in API
+type commandView struct {
+	Index      int               `json:"index"`
+	Data       string            `json:"data"`
+	Running    bool              `json:"running"`
+	Triggers   int               `json:"triggers"`
+	LastReply  string            `json:"last_reply,omitempty"`
+	LastResult map[string]string `json:"last_result,omitempty"`
+}
+
+// triggerView is JSON representation of trigger result
+type triggerView struct {
+	MessageID int64  `json:"message_id,omitempty"` // sent message or message with pressed button, bot reply if trigger waited for it
+	Answer    string `json:"answer,omitempty"`     // callback answer text, inline button commands only
+	Reply     string `json:"reply,omitempty"`      // bot reply text, if trigger waited for it
+	LatencyMs int64  `json:"latency_ms,omitempty"` // time from trigger to reply, if trigger waited for it
+}
+
+// newBotView builds botView from bot status
+func newBotView(b *Bot) *botView {
+	bs := b.Status()
+	bv := &botView{
+		Label:    bs.Label,
+		ChatID:   bs.ChatID,
+		Paused:   bs.Paused,
+		Commands: make([]commandView, 0, len(bs.Commands)),
+	}
+	if bs.Cooldown > 0 {
+		bv.Cooldown = bs.Cooldown.Round(time.Second).String()
+		bv.CooldownUntil = &bs.CooldownUntil
+	}
+	for i, cs := range bs.Commands {
+		bv.Commands = append(bv.Commands, commandView{
+			Index:      i,
+			Data:       cs.Data,
+			Running:    cs.Running,
+			Triggers:   cs.Triggers,
+			LastReply:  cs.LastReply,
+			LastResult: cs.LastResult,
+		})
+	}
+	return bv
+}
+
+// serveControl routes control API request
+func (bt *Bottalker) serveControl(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if path[0] != "bots" {
+		writeControlError(w, http.StatusNotFound, fmt.Errorf("Unknown path: %s", r.URL.Path))
+		return
+	}
+
+	if len(path) == 1 {
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		bt.RLock()
+		bots := append([]*Bot(nil), bt.Bots...)
+		bt.RUnlock()
+		views := make([]*botView, 0, len(bots))
+		for _, b := range bots {
+			views = append(views, newBotView(b))
+		}
+		writeControlJSON(w, http.StatusOK, views)
+		return
+	}
+
+	b := bt.GetBot(path[1])
+	if b == nil {
+		writeControlError(w, http.StatusNotFound, fmt.Errorf("Bot %s not found", path[1]))
+		return
+	}
+	switch {
+	case len(path) == 2:
+		if allowMethod(w, r, http.MethodGet) {
+			writeControlJSON(w, http.StatusOK, newBotView(b))
+		}
+	case len(path) == 3 && path[2] == "pause":
+		if allowMethod(w, r, http.MethodPost) {
+			b.Pause()
+			writeControlJSON(w, http.StatusOK, newBotView(b))
+		}
+	case len(path) == 3 && path[2] == "resume":
+		if allowMethod(w, r, http.MethodPost) {
+			b.Resume()
+			writeControlJSON(w, http.StatusOK, newBotView(b))
+		}
+	case len(path) == 3 && path[2] == "cooldown":
+		if allowMethod(w, r, http.MethodPost) {
+			bt.serveCooldown(w, r, b)
+		}
+	case len(path) == 5 && path[2] == "commands":
+		if allowMethod(w, r, http.MethodPost) {
+			bt.serveCommand(w, r, b, path[3], path[4])
+		}
+	default:
+		writeControlError(w, http.StatusNotFound, fmt.Errorf("Unknown path: %s", r.URL.Path))
+	}
+}
+
+// serveCooldown sets bot cooldown
+func (bt *Bottalker) serveCooldown(w http.ResponseWriter, r *http.Request, b *Bot) {
+	var body struct {
+		Duration ConfigDuration `json:"duration"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeControlError(w, http.StatusBadRequest, fmt.Errorf("Unable to parse body: %v", err))
+		return
+	}
+	b.SetCooldown(time.Duration(body.Duration))
+	b.getLogger().Info("Cooldown set via control API", "cooldown", time.Duration(body.Duration))
+	writeControlJSON(w, http.StatusOK, newBotView(b))
+}
+
+// serveCommand triggers, starts or stops bot command by index
+func (bt *Bottalker) serveCommand(w http.ResponseWriter, r *http.Request, b *Bot, index string, action string) {
+	i, err := strconv.Atoi(index)
+	if err != nil || i < 0 || i >= len(b.Commands) {
+		writeControlError(w, http.StatusNotFound, fmt.Errorf("Command %s not found", index))
+		return
+	}
+	bct := b.Commands[i]
+
+	switch action {
+	case "start":
+		bct.Start()
+		writeControlJSON(w, http.StatusOK, newBotView(b))
+		return
+	case "stop":
+		bct.Stop()
+		writeControlJSON(w, http.StatusOK, newBotView(b))
+		return
+	case "trigger":
+	default:
+		writeControlError(w, http.StatusNotFound, fmt.Errorf("Unknown command action: %s", action))
+		return
+	}
+
+	var wait time.Duration
+	if s := r.URL.Query().Get("wait"); s != "" {
+		if wait, err = time.ParseDuration(s); err != nil || wait <= 0 {
+			writeControlError(w, http.StatusBadRequest, fmt.Errorf("Unable to parse wait: %q", s))
+			return
+		}
+	}
+	var force bool
+	if s := r.URL.Query().Get("force"); s != "" {
+		if force, err = strconv.ParseBool(s); err != nil {
+			writeControlError(w, http.StatusBadRequest, fmt.Errorf("Unable to parse force: %q", s))
+			return
+		}
+	}
+
+	if b.getTelegramClient() == nil {
+		writeControlError(w, http.StatusServiceUnavailable, fmt.Errorf("Bot %s is not initialized", b.Label))
+		return
+	}
+	if !force {
+		if b.IsPaused() {
+			writeControlError(w, http.StatusConflict, fmt.Errorf("Bot %s is paused, use force=true to trigger anyway", b.Label))
+			return
+		}
+		if cd := b.GetCooldown(); cd > 0 {
+			writeControlError(w, http.StatusConflict, fmt.Errorf("Bot %s is cooling down for %v, use force=true to trigger anyway", b.Label, cd.Round(time.Second)))
+			return
+		}
+	}
+
+	b.getLogger().Info("Command triggered via control API", "command", bct.getData(), "force", force)
+	tv := &triggerView{}
+	var m *tdlib.Message
+	var bErr *BotError
+	if wait > 0 {
+		var reply *Reply
+		if reply, bErr = bct.TriggerAndWait(r.Context(), wait); reply != nil {
+			m = reply.Message
+			tv.Reply = reply.Text
+			tv.LatencyMs = reply.Latency().Milliseconds()
+		}
+	} else {
+		var answer *tdlib.CallbackQueryAnswer
+		if m, answer, bErr = b.triggerWithAnswer(r.Context(), bct, bt.ErrChan); bErr != nil {
+			b.sendError(r.Context(), bt.ErrChan, bErr)
+		}
+		if answer != nil {
+			tv.Answer = answer.Text
+		}
+	}
+	if bErr != nil {
+		writeControlError(w, http.StatusBadGateway, bErr)
+		return
+	}
+	if m != nil {
+		tv.MessageID = m.ID
+	}
+	writeControlJSON(w, http.StatusOK, tv)
+}
+
+// allowMethod responds with error unless request has `method`
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeControlError(w, http.StatusMethodNotAllowed, fmt.Errorf("Method %s not allowed", r.Method))
+	return false
+}
+
+// writeControlJSON responds with `v` encoded as JSON
+func writeControlJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		defaultLogger.Warn("Unable to write control API response", "error", err)
+	}
+}
+
+// writeControlError responds with JSON error
+func writeControlError(w http.ResponseWriter, status int, err error) {
+	writeControlJSON(w, status, map[string]string{"error": err.Error()})
+}
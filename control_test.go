@@ -0,0 +1,230 @@
+package bottalker_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+// controlRequest performs control API request and decodes JSON response into `v`
+func controlRequest(t *testing.T, srv *httptest.Server, method string, path string, body string, v interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("%s %s: unexpected content type: %s", method, path, ct)
+	}
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+type controlBot struct {
+	Label    string `json:"label"`
+	Paused   bool   `json:"paused"`
+	Cooldown string `json:"cooldown"`
+	Commands []struct {
+		Data      string `json:"data"`
+		Running   bool   `json:"running"`
+		LastReply string `json:"last_reply"`
+	} `json:"commands"`
+}
+
+func TestControlAPI(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	qbot.Send("Menu", bottalkertest.InlineKeyboard(
+		bottalkertest.Row(bottalkertest.CallbackButton("Balance", "bal")),
+	))
+	qbot.OnText("/start").Reply("Hello", nil)
+	qbot.OnCallback("bal").Answer("Balance: 5", false)
+
+	bt := runBottalker(t, srv, &bottalker.Bot{
+		Label:  "QBot",
+		ChatID: qbot.ID,
+		Commands: []bottalker.BotCommandType{
+			&bottalker.BotCommandChat{
+				BotCommand: bottalker.BotCommand{
+					Data:    []byte("/start"),
+					Passive: true,
+				},
+			},
+			&bottalker.BotCommandButton{
+				BotCommand: bottalker.BotCommand{
+					Data:    []byte("Balance"),
+					Passive: true,
+				},
+			},
+		},
+	})
+	api := httptest.NewServer(bt.ControlHandler())
+	defer api.Close()
+
+	var bots []controlBot
+	if status := controlRequest(t, api, http.MethodGet, "/bots", "", &bots); status != http.StatusOK {
+		t.Fatalf("unexpected status: %d", status)
+	}
+	if len(bots) != 1 || bots[0].Label != "QBot" || len(bots[0].Commands) != 2 || bots[0].Commands[0].Data != "/start" {
+		t.Fatalf("unexpected bots: %+v", bots)
+	}
+
+	var result struct {
+		MessageID int64  `json:"message_id"`
+		Answer    string `json:"answer"`
+		Reply     string `json:"reply"`
+	}
+	// triggers fail until bot is initialized
+	waitFor(t, 5*time.Second, func() bool {
+		return controlRequest(t, api, http.MethodPost, "/bots/QBot/commands/0/trigger?wait=5s", "", &result) == http.StatusOK
+	})
+	if result.Reply != "Hello" {
+		t.Errorf("unexpected trigger result: %+v", result)
+	}
+	if status := controlRequest(t, api, http.MethodPost, "/bots/QBot/commands/1/trigger", "", &result); status != http.StatusOK || result.Answer != "Balance: 5" {
+		t.Errorf("unexpected trigger result [%d]: %+v", status, result)
+	}
+
+	var bot controlBot
+	controlRequest(t, api, http.MethodPost, "/bots/QBot/pause", "", &bot)
+	if !bot.Paused || !bt.GetBot("QBot").IsPaused() {
+		t.Errorf("bot is not paused: %+v", bot)
+	}
+	if status := controlRequest(t, api, http.MethodPost, "/bots/QBot/commands/1/trigger", "", nil); status != http.StatusConflict {
+		t.Errorf("paused bot is triggered: %d", status)
+	}
+	result.Answer = ""
+	if status := controlRequest(t, api, http.MethodPost, "/bots/QBot/commands/1/trigger?force=true", "", &result); status != http.StatusOK || result.Answer != "Balance: 5" {
+		t.Errorf("unexpected forced trigger result [%d]: %+v", status, result)
+	}
+	controlRequest(t, api, http.MethodPost, "/bots/QBot/resume", "", &bot)
+	if bot.Paused {
+		t.Errorf("bot is not resumed: %+v", bot)
+	}
+	controlRequest(t, api, http.MethodPost, "/bots/QBot/cooldown", `{"duration": "5m"}`, &bot)
+	if bot.Cooldown != "5m0s" {
+		t.Errorf("unexpected cooldown: %+v", bot)
+	}
+	controlRequest(t, api, http.MethodPost, "/bots/QBot/commands/0/start", "", &bot)
+	if !bot.Commands[0].Running || bot.Commands[0].LastReply != "Hello" {
+		t.Errorf("unexpected command state: %+v", bot.Commands[0])
+	}
+
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	for path, want := range map[string]int{
+		"/bots/PBot":                    http.StatusNotFound,
+		"/bots/QBot/commands/7/trigger": http.StatusNotFound,
+		"/bots/QBot/cooldown":           http.StatusBadRequest,
+		"/bots/QBot":                    http.StatusMethodNotAllowed,
+	} {
+		if status := controlRequest(t, api, http.MethodPost, path, "", &apiErr); status != want || apiErr.Error == "" {
+			t.Errorf("%s: unexpected response [%d]: %+v", path, status, apiErr)
+		}
+	}
+}
+
+func TestControlAuth(t *testing.T) {
+	bt := &bottalker.Bottalker{ControlToken: "secret"}
+	api := httptest.NewServer(bt.ControlHandler())
+	defer api.Close()
+
+	for auth, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"secret":        http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Bearer secret": http.StatusOK,
+	} {
+		req, err := http.NewRequest(http.MethodGet, api.URL+"/bots", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := api.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("%q: unexpected status: %d", auth, resp.StatusCode)
+		}
+	}
+}
+
+func TestControlNotInitialized(t *testing.T) {
+	bt := &bottalker.Bottalker{
+		Bots: []*bottalker.Bot{{
+			Label: "QBot",
+			Commands: []bottalker.BotCommandType{
+				&bottalker.BotCommandChat{BotCommand: bottalker.BotCommand{Data: []byte("/start")}},
+			},
+		}},
+	}
+	api := httptest.NewServer(bt.ControlHandler())
+	defer api.Close()
+
+	if status := controlRequest(t, api, http.MethodPost, "/bots/QBot/commands/0/trigger", "", nil); status != http.StatusServiceUnavailable {
+		t.Errorf("unexpected status: %d", status)
+	}
+}
+
+func TestControlAddrWithoutToken(t *testing.T) {
+	bt := &bottalker.Bottalker{
+		TelegramClient: &bottalker.TelegramClient{
+			ID:      "test",
+			Backend: bottalkertest.NewServer(),
+		},
+		ControlAddr: ":0",
+	}
+	if err := bt.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "ControlToken") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestControlShutdownOnServeError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	// metrics can't be served on the same address, so control API must be shut down
+	bt := &bottalker.Bottalker{
+		TelegramClient: &bottalker.TelegramClient{
+			ID:      "test",
+			Backend: bottalkertest.NewServer(),
+		},
+		ControlAddr: addr,
+		MetricsAddr: addr,
+	}
+	if err := bt.Run(context.Background()); err == nil {
+		t.Fatal("expected metrics serve error")
+	}
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("control API is still listening: %v", err)
+	}
+	ln.Close()
+}
@@ -0,0 +1,144 @@
+package bottalker
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+)
+
+// Conversation is scripted dialog with bot: send text, expect reply, press button, expect edit, etc.
+//
+// It receives bot updates through bottalker message handler, so bottalker must be running.
+// Updates are buffered from the moment conversation is created, don't forget to `Close` it
+type Conversation struct {
+	bot    *Bot
+	ctx    context.Context
+	waiter *replyWaiter
+	last   *tdlib.Message // the latest bot message, buttons are pressed and edits are expected on it
+}
+
+// Conversation starts dialog with bot, `ctx` cancels pending expectations
+func (b *Bot) Conversation(ctx context.Context) *Conversation {
+	return &Conversation{
+		bot:    b,
+		ctx:    ctx,
+		waiter: b.addWaiter(),
+	}
+}
+
+// Close stops receiving bot updates
+func (c *Conversation) Close() {
+	c.bot.removeWaiter(c.waiter)
+}
+
+// Last returns the latest bot message received or pressed, nil if there is no such yet
+func (c *Conversation) Last() *tdlib.Message {
+	return c.last
+}
+
+// Send sends text message to bot
+func (c *Conversation) Send(text string) error {
+	if c.bot.getTelegramClient() == nil {
+		return fmt.Errorf("%s > Bot is not initialized", c.bot.Label)
+	}
+	if bErr := c.bot.sendText(text); bErr != nil {
+		return bErr
+	}
+	return nil
+}
+
+// Press presses inline button with `caption` on the latest bot message
+func (c *Conversation) Press(caption string) error {
+	if c.bot.getTelegramClient() == nil {
+		return fmt.Errorf("%s > Bot is not initialized", c.bot.Label)
+	}
+	if c.last == nil {
+		return fmt.Errorf("%s > No message to press [%s] on", c.bot.Label, caption)
+	}
+	if bErr := c.bot.pressButton(c.last, caption); bErr != nil {
+		return bErr
+	}
+	return nil
+}
+
+// Expect waits for new bot message with text matching `re`, any message matches if `re` is nil
+// Messages which don't match are skipped
+func (c *Conversation) Expect(re *regexp.Regexp, timeout time.Duration) (*tdlib.Message, error) {
+	return c.wait(timeout, func(update tdlib.TdMessage) (*tdlib.Message, error) {
+		newMessage, ok := update.(*tdlib.UpdateNewMessage)
+		if !ok {
+			return nil, nil
+		}
+		msg := newMessage.Message
+		if msg.IsOutgoing || !c.bot.isBotSender(msg) || !matchText(re, msg) {
+			return nil, nil
+		}
+		return msg, nil
+	})
+}
+
+// ExpectEdit waits for the latest bot message to be edited so its text matches `re`,
+// any edit matches if `re` is nil
+func (c *Conversation) ExpectEdit(re *regexp.Regexp, timeout time.Duration) (*tdlib.Message, error) {
+	if c.last == nil {
+		return nil, fmt.Errorf("%s > No message to expect edit of", c.bot.Label)
+	}
+	msgID := c.last.ID
+	return c.wait(timeout, func(update tdlib.TdMessage) (*tdlib.Message, error) {
+		switch update := update.(type) {
+		case *tdlib.UpdateMessageContent:
+			if update.MessageID != msgID {
+				return nil, nil
+			}
+		case *tdlib.UpdateMessageEdited:
+			if update.MessageID != msgID {
+				return nil, nil
+			}
+		default:
+			return nil, nil
+		}
+		msg, err := c.bot.getTelegramClient().Backend.GetMessage(c.bot.ChatID, msgID)
+		if err != nil {
+			return nil, fmt.Errorf("GetMessage [%d] failed: %s", msgID, err)
+		}
+		if !matchText(re, msg) {
+			return nil, nil
+		}
+		return msg, nil
+	})
+}
+
+// wait passes updates to `match` until it returns message or error
+func (c *Conversation) wait(timeout time.Duration, match func(update tdlib.TdMessage) (*tdlib.Message, error)) (*tdlib.Message, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		select {
+		case update := <-c.waiter.updates:
+			msg, err := match(update.update)
+			if err != nil {
+				return nil, err
+			}
+			if msg != nil {
+				c.last = msg
+				return msg, nil
+			}
+		case <-timer.C:
+			return nil, fmt.Errorf("%s > No expected message in %v", c.bot.Label, timeout)
+		case <-c.ctx.Done():
+			return nil, c.ctx.Err()
+		}
+	}
+}
+
+// matchText checks message text by regexp, nil regexp matches any message
+func matchText(re *regexp.Regexp, msg *tdlib.Message) bool {
+	if re == nil {
+		return true
+	}
+	text := GetMessageText(msg)
+	return text != nil && re.MatchString(*text)
+}
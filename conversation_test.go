@@ -0,0 +1,121 @@
+package bottalker_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+func TestConversation(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Hi", nil)
+	qbot.OnText("/start").Reply("Send 1 BTC?", bottalkertest.InlineKeyboard(
+		bottalkertest.Row(
+			bottalkertest.CallbackButton("Confirm", "confirm:42"),
+			bottalkertest.CallbackButton("Cancel", "cancel:42"),
+		),
+	))
+	qbot.OnCallback("confirm:42").Edit("Sent, tx 0xbeef", nil)
+
+	b := &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: time.Hour,
+	}
+	runBottalker(t, srv, b)
+
+	conv := b.Conversation(context.Background())
+	defer conv.Close()
+
+	// bot isn't initialized right after start
+	waitFor(t, 5*time.Second, func() bool {
+		return conv.Send("/start") == nil
+	})
+	if _, err := conv.Expect(regexp.MustCompile(`^Send \d+ BTC\?$`), 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := conv.Press("Confirm"); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := conv.ExpectEdit(regexp.MustCompile(`tx 0x[0-9a-f]+`), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bottalker.GetMessageButtons(msg)) != 0 {
+		t.Errorf("keyboard should be removed: %+v", msg.ReplyMarkup)
+	}
+
+	if err := conv.Press("Confirm"); err == nil {
+		t.Error("pressing missing button should fail")
+	}
+	if _, err := conv.Expect(nil, 50*time.Millisecond); err == nil {
+		t.Error("timeout expected")
+	}
+}
+
+func TestConversationAdhoc(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Hi", nil)
+	qbot.OnText("/bal_btc").Reply("BTC: 1.0", nil)
+	qbot.OnText("/start").Reply("Send 1 BTC?", bottalkertest.InlineKeyboard(
+		bottalkertest.Row(bottalkertest.CallbackButton("Confirm", "confirm:42")),
+	))
+	qbot.OnCallback("confirm:42").Answer("Sent", false)
+
+	balance := &bottalker.BotCommandChat{
+		BotCommand: bottalker.BotCommand{
+			Data:    []byte("/bal_btc"),
+			Passive: true,
+		},
+	}
+	replies := make(chan *bottalker.Reply, 100)
+	b := &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: time.Hour,
+		Commands:    []bottalker.BotCommandType{balance},
+		Replies:     replies,
+	}
+	bt := runBottalker(t, srv, b)
+	waitFor(t, 5*time.Second, func() bool {
+		_, bErr := balance.TriggerAndWait(context.Background(), time.Second)
+		return bErr == nil
+	})
+
+	conv := b.Conversation(context.Background())
+	defer conv.Close()
+	if err := conv.Send("/start"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conv.Expect(nil, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := conv.Press("Confirm"); err != nil {
+		t.Fatal(err)
+	}
+
+	// ad-hoc triggers don't take over replies of the last command
+	for reply := range replies {
+		if reply.Command != balance {
+			t.Fatalf("reply %q is attributed to %v", reply.Text, reply.Command)
+		}
+		if reply.Kind == bottalker.ReplyCallbackAnswer {
+			break
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	bt.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if metrics := rec.Body.String(); strings.Contains(metrics, "/start") || strings.Contains(metrics, "confirm:42") {
+		t.Errorf("ad-hoc triggers are measured:\n%s", metrics)
+	}
+}
@@ -0,0 +1,78 @@
+package bottalker
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// CooldownRule puts bot on cooldown when its reply matches
+type CooldownRule struct {
+	Match    *regexp.Regexp // reply text pattern; named groups `hours`, `minutes` or `seconds` override `Duration`, e.g. `wait (?P<minutes>\d+) min`
+	Duration time.Duration  // cooldown applied on match
+}
+
+// floodWaitRegexp matches Telegram rate limit errors:
+// `Too Many Requests: retry after 15` and `FLOOD_WAIT_15`
+var floodWaitRegexp = regexp.MustCompile(`(?:retry after |FLOOD_WAIT_)(\d+)`)
+
+// floodWait extracts wait duration from Telegram rate limit error
+func floodWait(err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+	m := floodWaitRegexp.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// cooldown returns rule cooldown if text matches
+func (cr *CooldownRule) cooldown(text string) (time.Duration, bool) {
+	m := cr.Match.FindStringSubmatch(text)
+	if m == nil {
+		return 0, false
+	}
+
+	var d time.Duration
+	var found bool
+	for i, name := range cr.Match.SubexpNames() {
+		var unit time.Duration
+		switch name {
+		case "hours":
+			unit = time.Hour
+		case "minutes":
+			unit = time.Minute
+		case "seconds":
+			unit = time.Second
+		default:
+			continue
+		}
+		n, err := strconv.Atoi(m[i])
+		if err != nil {
+			continue
+		}
+		d += time.Duration(n) * unit
+		found = true
+	}
+	if !found {
+		d = cr.Duration
+	}
+	return d, true
+}
+
+// applyCooldownRules checks reply text against bot cooldown rules
+func (b *Bot) applyCooldownRules(text string) {
+	for _, cr := range b.CooldownRules {
+		if d, ok := cr.cooldown(text); ok {
+			b.getLogger().Info("Reply matched cooldown rule, cooling down", "rule", cr.Match, "cooldown", d)
+			b.SetCooldown(d)
+			return
+		}
+	}
+}
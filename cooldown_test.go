@@ -0,0 +1,87 @@
+package bottalker_test
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+func TestCooldownOnFloodWait(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	rule := qbot.OnText("/bal_btc").Fail(errors.New("error! code: 429 msg: Too Many Requests: retry after 60"))
+
+	b := &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: 10 * time.Millisecond,
+		Commands: []bottalker.BotCommandType{
+			&bottalker.BotCommandChat{
+				BotCommand: bottalker.BotCommand{
+					Data: []byte("/bal_btc"),
+				},
+			},
+		},
+	}
+	runBottalker(t, srv, b)
+
+	waitFor(t, 5*time.Second, func() bool {
+		return b.GetCooldown() > 0
+	})
+	status := b.Status()
+	if status.Cooldown <= 50*time.Second || status.Cooldown > 60*time.Second {
+		t.Errorf("unexpected cooldown: %v", status.Cooldown)
+	}
+	if status.CooldownUntil.IsZero() {
+		t.Error("cooldown end expected")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if hits := rule.Hits(); hits != 1 {
+		t.Errorf("command triggered during cooldown, hits: %d", hits)
+	}
+
+	b.SetCooldown(0)
+	waitFor(t, 5*time.Second, func() bool {
+		return rule.Hits() > 1
+	})
+}
+
+func TestCooldownRule(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	qbot.OnText("/bal_btc").Reply("Too fast, wait 2 minutes", nil)
+
+	b := &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: 10 * time.Millisecond,
+		CooldownRules: []*bottalker.CooldownRule{
+			{
+				Match:    regexp.MustCompile(`wait (?P<minutes>\d+) minutes`),
+				Duration: time.Second,
+			},
+		},
+		Commands: []bottalker.BotCommandType{
+			&bottalker.BotCommandChat{
+				BotCommand: bottalker.BotCommand{
+					Data: []byte("/bal_btc"),
+				},
+			},
+		},
+	}
+	runBottalker(t, srv, b)
+
+	waitFor(t, 5*time.Second, func() bool {
+		return b.GetCooldown() > time.Minute
+	})
+	if cd := b.GetCooldown(); cd > 2*time.Minute {
+		t.Errorf("unexpected cooldown: %v", cd)
+	}
+}
@@ -1,13 +1,16 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"runtime"
 	"runtime/pprof"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/Arman92/go-tdlib"
@@ -37,7 +40,7 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
-	replies := make(chan *tdlib.TdMessage)
+	replies := make(chan *bottalker.Reply)
 	botsToCheck := []*bottalker.Bot{
 		{
 			Label:       "QBot",
@@ -60,24 +63,34 @@ func main() {
 	}
 	log.Println("Starting clients")
 	wg := &sync.WaitGroup{}
+	bt := newClient("checker", botsToCheck)
+
+	// Handle Ctrl+C, bottalker will shutdown tdlib gracefully
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := make(chan os.Signal, 2)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ch
+		cancel()
+	}()
 
 	wg.Add(1)
-	go func(*sync.WaitGroup, []*bottalker.Bot) {
+	go func(*sync.WaitGroup, *bottalker.Bottalker) {
 		defer wg.Done()
-		clientWithProxy("checker", botsToCheck)
-	}(wg, botsToCheck)
+		clientWithProxy(ctx, bt)
+	}(wg, bt)
 
-	go func(<-chan *tdlib.TdMessage) {
-		for msg := range replies {
-			log.Printf("Incoming message [%T]:\n", *msg)
-			switch (*msg).(type) {
-			case *tdlib.UpdateMessageContent:
-				log.Printf("\t%+v\n", (*msg).(*tdlib.UpdateMessageContent).NewContent)
-			case *tdlib.UpdateMessageEdited:
-				log.Printf("\t%+v\n", (*msg).(*tdlib.UpdateMessageEdited).ReplyMarkup)
-			case *tdlib.UpdateChatLastMessage:
-				log.Printf("\t%+v\n\nPrinting Message:\n\n", (*msg).(*tdlib.UpdateChatLastMessage).LastMessage)
-				bottalker.PrintMessage((*msg).(*tdlib.UpdateChatLastMessage).LastMessage)
+	go func(<-chan *bottalker.Reply) {
+		for reply := range replies {
+			log.Printf("Incoming %s reply from %s in %v:\n", reply.Kind, reply.Bot.Label, reply.Latency())
+			switch reply.Kind {
+			case bottalker.ReplyCallbackAnswer:
+				log.Printf("\tAnswer: %s\n", reply.Text)
+			default:
+				if reply.Message != nil {
+					bt.PrintMessage(reply.Message)
+				}
 			}
 		}
 	}(replies)
@@ -97,11 +110,10 @@ func main() {
 	wg.Wait()
 }
 
-func clientWithProxy(id string, bots []*bottalker.Bot) {
-	log.Println("\tStarting:", id)
+func newClient(id string, bots []*bottalker.Bot) *bottalker.Bottalker {
 	tgLogPath := fmt.Sprintf("./logs/tg_%s.log", id)
 
-	bt := &bottalker.Bottalker{
+	return &bottalker.Bottalker{
 		TelegramLog: &tgLogPath,
 		TelegramClient: &bottalker.TelegramClient{
 			ID: id,
@@ -112,6 +124,11 @@ func clientWithProxy(id string, bots []*bottalker.Bot) {
 		},
 		Bots: bots,
 	}
+}
 
-	bt.Run()
+func clientWithProxy(ctx context.Context, bt *bottalker.Bottalker) {
+	log.Println("\tStarting:", bt.TelegramClient.ID)
+	if err := bt.Run(ctx); err != nil {
+		log.Println("\tStopped with error:", err)
+	}
 }
@@ -0,0 +1,131 @@
+package bottalker
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ExtractRule extracts data from reply text
+//
+// Text can be narrowed by `Line` and `Field` selectors first, then `Match` is applied:
+// its named groups become result fields. Without named groups or `Match` at all,
+// the first group, whole match or selected text is stored as `Name`
+type ExtractRule struct {
+	Name      string         // result field name, used if there are no named groups
+	Match     *regexp.Regexp // regexp to apply to selected text, optional
+	Line      int            // 1-based line number, negative counts from the end, zero means whole text
+	Field     int            // 1-based field number of selected text, zero means whole text
+	Separator string         // field separator, whitespace if empty
+}
+
+// ReplyResult is data extracted from reply by command `Extract` rules
+//
+// Deprecated: use `Reply.Fields`, replies carry extracted data along with everything else
+type ReplyResult struct {
+	Bot         *Bot              // bot which replied
+	CommandType BotCommandType    // command reply is attributed to
+	Text        string            // reply text
+	Fields      map[string]string // extracted data
+	Date        time.Time         // when reply was received
+}
+
+// apply extracts fields from text to `fields`, returns false if rule didn't match
+func (er *ExtractRule) apply(text string, fields map[string]string) bool {
+	if er.Line != 0 {
+		lines := strings.Split(text, "\n")
+		n := er.Line
+		if n < 0 {
+			n += len(lines) + 1
+		}
+		if n < 1 || n > len(lines) {
+			return false
+		}
+		text = lines[n-1]
+	}
+	if er.Field != 0 {
+		var parts []string
+		if er.Separator == "" {
+			parts = strings.Fields(text)
+		} else {
+			parts = strings.Split(text, er.Separator)
+		}
+		if er.Field < 1 || er.Field > len(parts) {
+			return false
+		}
+		text = strings.TrimSpace(parts[er.Field-1])
+	}
+	if er.Match == nil {
+		if er.Name != "" {
+			fields[er.Name] = text
+		}
+		return true
+	}
+
+	match := er.Match.FindStringSubmatch(text)
+	if match == nil {
+		return false
+	}
+	var named bool
+	for i, name := range er.Match.SubexpNames() {
+		if name != "" {
+			fields[name] = match[i]
+			named = true
+		}
+	}
+	if !named && er.Name != "" {
+		if len(match) > 1 {
+			fields[er.Name] = match[1]
+		} else {
+			fields[er.Name] = match[0]
+		}
+	}
+	return true
+}
+
+// extract applies rules to text, nil is returned if there are no rules or none of them matched
+func extract(rules []*ExtractRule, text string) map[string]string {
+	fields := make(map[string]string)
+	var matched bool
+	for _, er := range rules {
+		if er.apply(text, fields) {
+			matched = true
+		}
+	}
+	if !matched {
+		return nil
+	}
+	return fields
+}
+
+// extractResult applies command rules to reply and passes result to `Results` unless `ctx` is canceled,
+// extracted fields are returned, nil if nothing is extracted
+func (b *Bot) extractResult(ctx context.Context, bct BotCommandType, text string) map[string]string {
+	if bct == nil {
+		return nil
+	}
+	fields := extract(bct.getExtractRules(), text)
+	if fields == nil {
+		return nil
+	}
+
+	cr := bct.getReport()
+	cr.Lock()
+	cr.lastResult = fields
+	cr.Unlock()
+
+	if b.Results != nil {
+		select {
+		case b.Results <- &ReplyResult{
+			Bot:         b,
+			CommandType: bct,
+			Text:        text,
+			Fields:      fields,
+			Date:        time.Now(),
+		}:
+		case <-ctx.Done():
+		}
+	}
+	return fields
+}
@@ -0,0 +1,78 @@
+package bottalker_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+func TestExtractResults(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	qbot.OnText("/bal_btc").Reply("Balance\nBTC: 1.25 (~ 30000 USD)\nUpdated: 12:00", nil)
+
+	replies := make(chan *bottalker.Reply, 100)
+	results := make(chan *bottalker.ReplyResult, 100)
+	bcc := &bottalker.BotCommandChat{
+		BotCommand: bottalker.BotCommand{
+			Data: []byte("/bal_btc"),
+			Extract: []*bottalker.ExtractRule{
+				{Match: regexp.MustCompile(`(?P<currency>[A-Z]+): (?P<amount>[\d.]+)`)},
+				{Name: "usd", Line: 2, Field: 4, Separator: " "},
+				{Name: "updated", Line: -1, Match: regexp.MustCompile(`\d+:\d+`)},
+				{Name: "missing", Match: regexp.MustCompile(`ETH`)},
+			},
+		},
+	}
+	b := &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: 10 * time.Millisecond,
+		Replies:     replies,
+		Results:     results,
+		Commands:    []bottalker.BotCommandType{bcc},
+	}
+	runBottalker(t, srv, b)
+
+	var reply *bottalker.Reply
+	for reply == nil || reply.Fields == nil {
+		select {
+		case reply = <-replies:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no reply with fields")
+		}
+	}
+	if reply.Bot != b || reply.Command != bcc {
+		t.Errorf("reply is attributed wrong: %+v", reply)
+	}
+	expected := map[string]string{
+		"currency": "BTC",
+		"amount":   "1.25",
+		"usd":      "30000",
+		"updated":  "12:00",
+	}
+	if len(reply.Fields) != len(expected) {
+		t.Errorf("unexpected fields: %v", reply.Fields)
+	}
+	for k, v := range expected {
+		if reply.Fields[k] != v {
+			t.Errorf("%s: expected %q, got %q", k, v, reply.Fields[k])
+		}
+	}
+	// deprecated results channel still gets the same data
+	select {
+	case result := <-results:
+		if result.CommandType != bcc || result.Fields["amount"] != "1.25" {
+			t.Errorf("unexpected result: %+v", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no result")
+	}
+	if status := b.Status(); status.Commands[0].LastResult["amount"] != "1.25" {
+		t.Errorf("unexpected status result: %v", status.Commands[0].LastResult)
+	}
+}
@@ -0,0 +1,338 @@
+package bottalker
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+)
+
+// historyPageLimit is how many messages are requested per history page
+const historyPageLimit = 50
+
+// ExportFormatEnum is chat history export format
+type ExportFormatEnum int
+
+// Enum to choose export format
+const (
+	ExportJSONL    ExportFormatEnum = iota // JSON object per line, see HistoryMessage
+	ExportCSV                              // header and row per message, buttons are listed line by line in single cell
+	ExportMarkdown                         // human readable transcript
+)
+
+// String returns export format name
+func (ef ExportFormatEnum) String() string {
+	switch ef {
+	case ExportJSONL:
+		return "jsonl"
+	case ExportCSV:
+		return "csv"
+	case ExportMarkdown:
+		return "markdown"
+	}
+	return fmt.Sprintf("unknown(%d)", int(ef))
+}
+
+// ParseExportFormat returns export format by name: `jsonl`, `csv` or `markdown` (`md`)
+func ParseExportFormat(s string) (ExportFormatEnum, error) {
+	switch strings.ToLower(s) {
+	case "jsonl":
+		return ExportJSONL, nil
+	case "csv":
+		return ExportCSV, nil
+	case "markdown", "md":
+		return ExportMarkdown, nil
+	}
+	return 0, fmt.Errorf("Unknown export format: %q", s)
+}
+
+// HistoryRange limits chat history by date and message ID, zero fields don't limit it
+type HistoryRange struct {
+	Since  time.Time // messages sent at or after
+	Until  time.Time // messages sent at or before
+	FromID int64     // messages with ID greater or equal
+	ToID   int64     // messages with ID less or equal
+}
+
+// contains checks if message is within range
+func (hr HistoryRange) contains(msg *tdlib.Message) bool {
+	date := time.Unix(int64(msg.Date), 0)
+	return !hr.passed(msg) &&
+		(hr.ToID == 0 || msg.ID <= hr.ToID) &&
+		(hr.Until.IsZero() || !date.After(hr.Until))
+}
+
+// passed checks if message is older than range, so history walk may stop
+func (hr HistoryRange) passed(msg *tdlib.Message) bool {
+	return (hr.FromID != 0 && msg.ID < hr.FromID) ||
+		(!hr.Since.IsZero() && time.Unix(int64(msg.Date), 0).Before(hr.Since))
+}
+
+// HistoryMessage is exported chat message
+type HistoryMessage struct {
+	ID       int64           `json:"id"`
+	Date     time.Time       `json:"date"`
+	EditedAt *time.Time      `json:"edited_at,omitempty"` // the last edit, Telegram keeps the latest message version only
+	Sender   string          `json:"sender"`              // `user:ID` or `chat:ID`
+	Outgoing bool            `json:"outgoing"`            // sent by our client
+	ReplyTo  int64           `json:"reply_to,omitempty"`  // ID of message this one replies to
+	Content  string          `json:"content"`             // tdlib content type, like `messageText` or `messagePhoto`
+	Text     string          `json:"text"`                // empty for non-text messages
+	Buttons  []HistoryButton `json:"buttons,omitempty"`
+}
+
+// HistoryButton is exported message button
+type HistoryButton struct {
+	Row     int    `json:"row"`
+	Column  int    `json:"column"`
+	Kind    string `json:"kind"`              // see ButtonKindEnum
+	Text    string `json:"text"`              // caption
+	Payload string `json:"payload,omitempty"` // see `MessageButton.Payload`
+	URL     string `json:"url,omitempty"`     // URL and login URL buttons only
+}
+
+// String returns button like `[0:1] Refresh (callback): /bal_btc`
+func (hb HistoryButton) String() string {
+	s := fmt.Sprintf("[%d:%d] %s (%s)", hb.Row, hb.Column, hb.Text, hb.Kind)
+	switch {
+	case hb.URL != "":
+		return s + ": " + hb.URL
+	case hb.Payload != "":
+		return s + ": " + hb.Payload
+	}
+	return s
+}
+
+// NewHistoryMessage converts Telegram message to its exported representation
+func NewHistoryMessage(msg *tdlib.Message) *HistoryMessage {
+	hm := &HistoryMessage{
+		ID:       msg.ID,
+		Date:     time.Unix(int64(msg.Date), 0).UTC(),
+		Outgoing: msg.IsOutgoing,
+		ReplyTo:  msg.ReplyToMessageID,
+	}
+	if msg.EditDate != 0 {
+		edited := time.Unix(int64(msg.EditDate), 0).UTC()
+		hm.EditedAt = &edited
+	}
+	switch sender := msg.Sender.(type) {
+	case *tdlib.MessageSenderUser:
+		hm.Sender = fmt.Sprintf("user:%d", sender.UserID)
+	case *tdlib.MessageSenderChat:
+		hm.Sender = fmt.Sprintf("chat:%d", sender.ChatID)
+	}
+	if msg.Content != nil {
+		hm.Content = string(msg.Content.GetMessageContentEnum())
+	}
+	if text := GetMessageText(msg); text != nil {
+		hm.Text = *text
+	}
+	for _, btn := range GetMessageButtons(msg) {
+		hm.Buttons = append(hm.Buttons, HistoryButton{
+			Row:     btn.Row,
+			Column:  btn.Column,
+			Kind:    btn.Kind.String(),
+			Text:    btn.Text,
+			Payload: string(btn.Payload),
+			URL:     btn.URL,
+		})
+	}
+	return hm
+}
+
+// walkHistory pages through chat history within `hr` from the newest message to the oldest one
+func (tc *TelegramClient) walkHistory(chatID int64, hr HistoryRange, fn func(msg *tdlib.Message)) error {
+	fromID := hr.ToID
+	if fromID == 0 && !hr.Until.IsZero() {
+		// starting from the last message before `Until`, history is walked from the latest one if there is no such
+		if msg, err := tc.Backend.GetChatMessageByDate(chatID, int32(hr.Until.Unix())); err == nil && msg != nil {
+			fromID = msg.ID
+		}
+	}
+
+	oldestID := int64(0)
+	for full := false; ; {
+		// pausing between full pages only, short page is most likely the last one
+		if full {
+			time.Sleep(100 * time.Millisecond)
+		}
+		msgs, err := tc.Backend.GetChatHistory(chatID, fromID, 0, historyPageLimit, false)
+		if err != nil {
+			return fmt.Errorf("GetChatHistory failed: %v", err)
+		}
+		var walked int
+		for i := range msgs.Messages {
+			msg := &msgs.Messages[i]
+			// page starts with `fromID` message, which is already walked
+			if oldestID != 0 && msg.ID >= oldestID {
+				continue
+			}
+			if hr.passed(msg) {
+				return nil
+			}
+			oldestID = msg.ID
+			walked++
+			if hr.contains(msg) {
+				fn(msg)
+			}
+		}
+		if walked == 0 {
+			return nil
+		}
+		fromID = oldestID
+		full = len(msgs.Messages) >= historyPageLimit
+	}
+}
+
+// getHistory pages through the whole chat history, so tdlib loads it
+func (tc *TelegramClient) getHistory(chatID int64) error {
+	return tc.walkHistory(chatID, HistoryRange{}, func(*tdlib.Message) {})
+}
+
+// History returns chat messages within `hr`, oldest first
+func (tc *TelegramClient) History(chatID int64, hr HistoryRange) ([]*tdlib.Message, error) {
+	var msgs []*tdlib.Message
+	err := tc.walkHistory(chatID, hr, func(msg *tdlib.Message) {
+		msgs = append(msgs, msg)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+	return msgs, nil
+}
+
+// ExportHistory writes chat messages within `hr` to `w` oldest first
+func (tc *TelegramClient) ExportHistory(w io.Writer, chatID int64, hr HistoryRange, format ExportFormatEnum) error {
+	msgs, err := tc.History(chatID, hr)
+	if err != nil {
+		return fmt.Errorf("Unable to export history: %v", err)
+	}
+	hms := make([]*HistoryMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		hms = append(hms, NewHistoryMessage(msg))
+	}
+
+	switch format {
+	case ExportJSONL:
+		err = writeHistoryJSONL(w, hms)
+	case ExportCSV:
+		err = writeHistoryCSV(w, hms)
+	case ExportMarkdown:
+		title := strconv.FormatInt(chatID, 10)
+		if chat, err := tc.Backend.GetChat(chatID); err == nil && chat.Title != "" {
+			title = chat.Title
+		}
+		err = writeHistoryMarkdown(w, chatID, title, hms)
+	default:
+		return fmt.Errorf("Unable to export history: unknown format %v", format)
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to export history: %v", err)
+	}
+	return nil
+}
+
+// ExportHistory writes bot chat messages within `hr` to `w` oldest first, bot should be started
+func (b *Bot) ExportHistory(w io.Writer, hr HistoryRange, format ExportFormatEnum) error {
+	tc := b.getTelegramClient()
+	if tc == nil {
+		return fmt.Errorf("Unable to export history: bot %s is not initialized", b.Label)
+	}
+	return tc.ExportHistory(w, b.ChatID, hr, format)
+}
+
+func writeHistoryJSONL(w io.Writer, hms []*HistoryMessage) error {
+	enc := json.NewEncoder(w)
+	for _, hm := range hms {
+		if err := enc.Encode(hm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeHistoryCSV(w io.Writer, hms []*HistoryMessage) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "date", "edited_at", "sender", "outgoing", "reply_to", "content", "text", "buttons"})
+	for _, hm := range hms {
+		var edited, replyTo string
+		if hm.EditedAt != nil {
+			edited = hm.EditedAt.Format(time.RFC3339)
+		}
+		if hm.ReplyTo != 0 {
+			replyTo = strconv.FormatInt(hm.ReplyTo, 10)
+		}
+		buttons := make([]string, 0, len(hm.Buttons))
+		for _, hb := range hm.Buttons {
+			buttons = append(buttons, hb.String())
+		}
+		cw.Write([]string{
+			strconv.FormatInt(hm.ID, 10),
+			hm.Date.Format(time.RFC3339),
+			edited,
+			hm.Sender,
+			strconv.FormatBool(hm.Outgoing),
+			replyTo,
+			hm.Content,
+			hm.Text,
+			strings.Join(buttons, "\n"),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeHistoryMarkdown(w io.Writer, chatID int64, title string, hms []*HistoryMessage) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", title)
+	if len(hms) == 0 {
+		sb.WriteString("No messages.\n")
+	} else {
+		fmt.Fprintf(&sb, "%d messages from %s to %s.\n", len(hms),
+			hms[0].Date.Format("2006-01-02 15:04:05 MST"), hms[len(hms)-1].Date.Format("2006-01-02 15:04:05 MST"))
+	}
+
+	// private chat ID is ID of user we're talking with
+	chatSenders := map[string]bool{fmt.Sprintf("user:%d", chatID): true, fmt.Sprintf("chat:%d", chatID): true}
+	for _, hm := range hms {
+		sender := hm.Sender
+		switch {
+		case hm.Outgoing:
+			sender = "me"
+		case chatSenders[sender]:
+			sender = title
+		}
+		fmt.Fprintf(&sb, "\n**%s** · %s · #%d", sender, hm.Date.Format("2006-01-02 15:04:05"), hm.ID)
+		if hm.ReplyTo != 0 {
+			fmt.Fprintf(&sb, " · reply to #%d", hm.ReplyTo)
+		}
+		if hm.EditedAt != nil {
+			fmt.Fprintf(&sb, " · edited %s", hm.EditedAt.Format("2006-01-02 15:04:05"))
+		}
+		sb.WriteString("\n\n")
+
+		text := hm.Text
+		if text == "" {
+			text = fmt.Sprintf("_%s_", hm.Content)
+		}
+		for _, line := range strings.Split(text, "\n") {
+			fmt.Fprintf(&sb, "> %s\n", line)
+		}
+		if len(hm.Buttons) > 0 {
+			sb.WriteString("\n")
+			for _, hb := range hm.Buttons {
+				fmt.Fprintf(&sb, "- `%s`\n", hb)
+			}
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -0,0 +1,151 @@
+package bottalker_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+// historyChat prepares chat with welcome message edited by button press and `pings` exchanges
+func historyChat(t *testing.T, pings int) (*bottalkertest.Server, *bottalkertest.Bot) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	welcome := qbot.Send("Welcome", bottalkertest.InlineKeyboard(
+		bottalkertest.Row(bottalkertest.CallbackButton("Refresh", "/refresh")),
+	))
+	qbot.OnCallback("/refresh").Edit("Welcome back", bottalkertest.InlineKeyboard(
+		bottalkertest.Row(bottalkertest.URLButton("Site", "https://example.com")),
+	))
+	qbot.OnText("/ping").Reply("pong\nok", nil)
+
+	if _, err := srv.GetCallbackQueryAnswer(qbot.ID, welcome.ID, tdlib.NewCallbackQueryPayloadData([]byte("/refresh"))); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < pings; i++ {
+		_, err := srv.SendMessage(qbot.ID, 0, 0, nil, nil,
+			tdlib.NewInputMessageText(tdlib.NewFormattedText("/ping", nil), true, true))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return srv, qbot
+}
+
+func TestHistory(t *testing.T) {
+	srv, qbot := historyChat(t, 60)
+	tc := &bottalker.TelegramClient{ID: "test", Backend: srv}
+
+	// more than a page
+	msgs, err := tc.History(qbot.ID, bottalker.HistoryRange{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 121 {
+		t.Fatalf("unexpected messages count: %d", len(msgs))
+	}
+	for i := 1; i < len(msgs); i++ {
+		if msgs[i].ID <= msgs[i-1].ID {
+			t.Fatalf("messages aren't sorted oldest first: %d after %d", msgs[i].ID, msgs[i-1].ID)
+		}
+	}
+
+	msgs, err = tc.History(qbot.ID, bottalker.HistoryRange{FromID: msgs[10].ID, ToID: msgs[19].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 10 {
+		t.Errorf("unexpected messages count in ID range: %d", len(msgs))
+	}
+
+	msgs, err = tc.History(qbot.ID, bottalker.HistoryRange{Since: time.Now().Add(time.Hour)})
+	if err != nil || len(msgs) != 0 {
+		t.Errorf("unexpected messages in future: %d, %v", len(msgs), err)
+	}
+	msgs, err = tc.History(qbot.ID, bottalker.HistoryRange{Until: time.Now().Add(-time.Hour)})
+	if err != nil || len(msgs) != 0 {
+		t.Errorf("unexpected messages in past: %d, %v", len(msgs), err)
+	}
+}
+
+func TestExportHistory(t *testing.T) {
+	srv, qbot := historyChat(t, 1)
+	tc := &bottalker.TelegramClient{ID: "test", Backend: srv}
+
+	var buf bytes.Buffer
+	if err := tc.ExportHistory(&buf, qbot.ID, bottalker.HistoryRange{}, bottalker.ExportJSONL); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], `"edited_at":`) {
+		t.Fatalf("unexpected JSONL:\n%s", buf.String())
+	}
+	var hms []bottalker.HistoryMessage
+	for _, line := range lines {
+		var hm bottalker.HistoryMessage
+		if err := json.Unmarshal([]byte(line), &hm); err != nil {
+			t.Fatalf("unable to parse %s: %v", line, err)
+		}
+		hms = append(hms, hm)
+	}
+	if hm := hms[0]; hm.Text != "Welcome back" || hm.EditedAt == nil || hm.Outgoing || len(hm.Buttons) != 1 || hm.Buttons[0].URL != "https://example.com" {
+		t.Errorf("unexpected edited message: %+v", hm)
+	}
+	if hm := hms[1]; hm.Text != "/ping" || !hm.Outgoing || hm.Sender != "user:1" || hm.Content != "messageText" {
+		t.Errorf("unexpected outgoing message: %+v", hm)
+	}
+	if hm := hms[2]; hm.Text != "pong\nok" || hm.ReplyTo != hms[1].ID || hm.Sender != "user:600120108" {
+		t.Errorf("unexpected reply: %+v", hm)
+	}
+
+	buf.Reset()
+	if err := tc.ExportHistory(&buf, qbot.ID, bottalker.HistoryRange{}, bottalker.ExportCSV); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[0][2] != "edited_at" || records[0][7] != "text" || records[3][7] != "pong\nok" || records[1][8] != "[0:0] Site (url): https://example.com" {
+		t.Errorf("unexpected CSV: %q", records)
+	}
+
+	buf.Reset()
+	if err := tc.ExportHistory(&buf, qbot.ID, bottalker.HistoryRange{}, bottalker.ExportMarkdown); err != nil {
+		t.Fatal(err)
+	}
+	for _, part := range []string{
+		"# QBot\n\n3 messages from ",
+		"> Welcome back\n\n- `[0:0] Site (url): https://example.com`\n",
+		"**me** · ",
+		"> pong\n> ok\n",
+	} {
+		if !strings.Contains(buf.String(), part) {
+			t.Errorf("markdown doesn't contain %q:\n%s", part, buf.String())
+		}
+	}
+	if !strings.Contains(buf.String(), "**QBot** · ") || !strings.Contains(buf.String(), " · edited ") {
+		t.Errorf("unexpected markdown:\n%s", buf.String())
+	}
+}
+
+func TestParseExportFormat(t *testing.T) {
+	for name, ef := range map[string]bottalker.ExportFormatEnum{
+		"jsonl": bottalker.ExportJSONL,
+		"CSV":   bottalker.ExportCSV,
+		"md":    bottalker.ExportMarkdown,
+	} {
+		if got, err := bottalker.ParseExportFormat(name); err != nil || got != ef {
+			t.Errorf("%s: got %v, %v", name, got, err)
+		}
+	}
+	if _, err := bottalker.ParseExportFormat("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
@@ -0,0 +1,94 @@
+package bottalker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+)
+
+// BotCommandKeyboard pressing reply keyboard button by sending its text, `BotCommand.Data` is the caption
+//
+// Reply keyboard stays until bot replaces or removes it, so button is looked up
+// in the newest message which shows or removes keyboard
+type BotCommandKeyboard struct {
+	Match CaptionMatchEnum // caption matching, exact by default
+	Depth int32            // how many latest messages are searched for keyboard, 20 if zero
+	BotCommand
+}
+
+// findButton returns matching button of current reply keyboard
+func (bck *BotCommandKeyboard) findButton() (*MessageButton, error) {
+	cm, err := bck.getMatcher()
+	if err != nil {
+		return nil, err
+	}
+	msgs, depth, err := bck.latestMessages(bck.Depth)
+	if err != nil {
+		return nil, err
+	}
+	// history goes from the newest message to the oldest one
+	for i := range msgs.Messages {
+		msg := &msgs.Messages[i]
+		switch msg.ReplyMarkup.(type) {
+		case *tdlib.ReplyMarkupRemoveKeyboard:
+			return nil, fmt.Errorf("Keyboard is removed by message [%d]", msg.ID)
+		case *tdlib.ReplyMarkupShowKeyboard:
+			for _, btn := range GetMessageButtons(msg) {
+				if cm.matches(btn.Text) {
+					return btn, nil
+				}
+			}
+			return nil, fmt.Errorf("Button [%s] not found in keyboard of message [%d]", bck.Data, msg.ID)
+		}
+	}
+	return nil, fmt.Errorf("Keyboard not found in %d latest messages", depth)
+}
+
+// Trigger looking for keyboard button and sending its text
+func (bck *BotCommandKeyboard) Trigger() (m *tdlib.Message, bErr *BotError) {
+	b, bErr := bck.getInitializedBot(bck)
+	if bErr != nil {
+		return nil, bErr
+	}
+	start := b.startTrigger()
+	defer func() { b.observeTrigger(bck, start, bErr) }()
+	btn, err := bck.findButton()
+	if err != nil {
+		return nil, &BotError{
+			Err:         err,
+			ErrType:     BotErrError,
+			Bot:         b,
+			CommandType: bck,
+		}
+	}
+	return b.sendKeyboardButton(bck, btn)
+}
+
+// TriggerAndWait pressing keyboard button and waiting for bot message replying to it
+func (bck *BotCommandKeyboard) TriggerAndWait(ctx context.Context, timeout time.Duration) (*Reply, *BotError) {
+	return bck.getBot().triggerAndWait(ctx, bck, timeout)
+}
+
+// sendKeyboardButton presses reply keyboard button, buttons requesting user data can't be pressed this way
+func (b *Bot) sendKeyboardButton(bct BotCommandType, btn *MessageButton) (*tdlib.Message, *BotError) {
+	if btn.Request != "" {
+		return nil, &BotError{
+			Err:         fmt.Errorf("Button [%s] requests %s, it can't be pressed", btn.Text, btn.Request),
+			ErrType:     BotErrError,
+			Bot:         b,
+			CommandType: bct,
+		}
+	}
+	m, err := b.TelegramClient.sendText(b.ChatID, btn.Text)
+	if err != nil {
+		return nil, &BotError{
+			Err:         fmt.Errorf("SendMessage [%s] failed: %s", btn.Text, err),
+			ErrType:     BotErrFatal,
+			Bot:         b,
+			CommandType: bct,
+		}
+	}
+	return m, nil
+}
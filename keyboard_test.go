@@ -0,0 +1,86 @@
+package bottalker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+func TestGetMessageButtonsKeyboard(t *testing.T) {
+	msg := &tdlib.Message{
+		ReplyMarkup: bottalkertest.Keyboard(
+			bottalkertest.KeyboardRow(bottalkertest.TextButton("Balance"), bottalkertest.PhoneButton("Share phone")),
+			bottalkertest.KeyboardRow(bottalkertest.LocationButton("Share location"), bottalkertest.PollButton("New poll")),
+		),
+	}
+	buttons := bottalker.GetMessageButtons(msg)
+	expected := []bottalker.MessageButton{
+		{Text: "Balance", Payload: []byte("Balance")},
+		{Text: "Share phone", Request: bottalker.ButtonRequestPhone},
+		{Text: "Share location", Request: bottalker.ButtonRequestLocation},
+		{Text: "New poll", Request: bottalker.ButtonRequestPoll},
+	}
+	if len(buttons) != len(expected) {
+		t.Fatalf("unexpected buttons: %+v", buttons)
+	}
+	for i, btn := range buttons {
+		if btn.Text != expected[i].Text || string(btn.Payload) != string(expected[i].Payload) || btn.Request != expected[i].Request {
+			t.Errorf("button %d: expected %+v, got %+v", i, expected[i], btn)
+		}
+	}
+}
+
+func TestKeyboardCommand(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Menu", bottalkertest.Keyboard(
+		bottalkertest.KeyboardRow(bottalkertest.TextButton("💰 Balance"), bottalkertest.PhoneButton("Share phone")),
+	))
+	qbot.Send("Just a message", nil)
+	qbot.OnText("💰 Balance").Reply("BTC: 1.0", nil)
+
+	balance := &bottalker.BotCommandKeyboard{
+		Match: bottalker.CaptionRegexp,
+		BotCommand: bottalker.BotCommand{
+			Data:    []byte("Balance$"),
+			Passive: true,
+		},
+	}
+	phone := &bottalker.BotCommandKeyboard{
+		BotCommand: bottalker.BotCommand{
+			Data:    []byte("Share phone"),
+			Passive: true,
+		},
+	}
+	runBottalker(t, srv, &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: time.Hour,
+		Commands:    []bottalker.BotCommandType{balance, phone},
+	})
+
+	var reply string
+	waitFor(t, 5*time.Second, func() bool {
+		r, bErr := balance.TriggerAndWait(context.Background(), time.Second)
+		if bErr != nil {
+			return false
+		}
+		reply = r.Text
+		return true
+	})
+	if reply != "BTC: 1.0" {
+		t.Errorf("unexpected reply: %s", reply)
+	}
+	if _, bErr := phone.Trigger(); bErr == nil {
+		t.Error("requesting button shouldn't be pressed")
+	}
+
+	qbot.Send("Bye", bottalkertest.RemoveKeyboard())
+	if _, bErr := balance.Trigger(); bErr == nil {
+		t.Error("removed keyboard shouldn't be pressed")
+	}
+}
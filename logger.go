@@ -0,0 +1,247 @@
+package bottalker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Logger is levelled structured logger used by bottalker, `keyvals` are key/value pairs like `"bot", "QBot"`
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+	With(keyvals ...interface{}) Logger // returns logger adding `keyvals` to every entry
+}
+
+// LogLevelEnum is log entry severity
+type LogLevelEnum int
+
+// Enum to switch log levels
+const (
+	LogDebug LogLevelEnum = iota // verbose details like every tick
+	LogInfo                      // lifecycle events, default level
+	LogWarn                      // recoverable problems
+	LogError                     // failures
+)
+
+// String returns log level name
+func (ll LogLevelEnum) String() string {
+	switch ll {
+	case LogDebug:
+		return "debug"
+	case LogInfo:
+		return "info"
+	case LogWarn:
+		return "warn"
+	case LogError:
+		return "error"
+	}
+	return fmt.Sprintf("unknown(%d)", int(ll))
+}
+
+// ParseLogLevel returns log level by name: `debug`, `info`, `warn` or `error`
+func ParseLogLevel(s string) (LogLevelEnum, error) {
+	for ll := LogDebug; ll <= LogError; ll++ {
+		if strings.ToLower(s) == ll.String() {
+			return ll, nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown log level: %q", s)
+}
+
+// defaultLogger is used when Bottalker has no `Logger`
+var defaultLogger Logger = NewTextLogger(os.Stderr, LogInfo)
+
+// NewTextLogger returns logger writing logfmt lines like `time=... level=info msg="Bot started" bot=QBot` to `w`,
+// entries below `level` are dropped
+func NewTextLogger(w io.Writer, level LogLevelEnum) Logger {
+	return &streamLogger{w: w, level: level, mu: &sync.Mutex{}}
+}
+
+// NewJSONLogger returns logger writing JSON object per line like `{"time":...,"level":"info","msg":"Bot started","bot":"QBot"}` to `w`,
+// entries below `level` are dropped
+func NewJSONLogger(w io.Writer, level LogLevelEnum) Logger {
+	return &streamLogger{w: w, level: level, json: true, mu: &sync.Mutex{}}
+}
+
+// streamLogger is default Logger implementation
+type streamLogger struct {
+	w      io.Writer
+	level  LogLevelEnum
+	json   bool          // JSON output, logfmt otherwise
+	fields []interface{} // added to every entry, see `With`
+	mu     *sync.Mutex   // shared with derived loggers, so lines don't interleave
+}
+
+// Debug logs debug entry
+func (sl *streamLogger) Debug(msg string, keyvals ...interface{}) {
+	sl.log(LogDebug, msg, keyvals)
+}
+
+// Info logs info entry
+func (sl *streamLogger) Info(msg string, keyvals ...interface{}) {
+	sl.log(LogInfo, msg, keyvals)
+}
+
+// Warn logs warning entry
+func (sl *streamLogger) Warn(msg string, keyvals ...interface{}) {
+	sl.log(LogWarn, msg, keyvals)
+}
+
+// Error logs error entry
+func (sl *streamLogger) Error(msg string, keyvals ...interface{}) {
+	sl.log(LogError, msg, keyvals)
+}
+
+// With returns logger adding `keyvals` to every entry
+func (sl *streamLogger) With(keyvals ...interface{}) Logger {
+	fields := make([]interface{}, 0, len(sl.fields)+len(keyvals))
+	fields = append(append(fields, sl.fields...), keyvals...)
+	return &streamLogger{w: sl.w, level: sl.level, json: sl.json, fields: fields, mu: sl.mu}
+}
+
+func (sl *streamLogger) log(level LogLevelEnum, msg string, keyvals []interface{}) {
+	if level < sl.level {
+		return
+	}
+	entry := []interface{}{"time", time.Now().Format(time.RFC3339Nano), "level", level.String(), "msg", msg}
+	entry = append(append(entry, sl.fields...), keyvals...)
+	if len(entry)%2 != 0 {
+		entry = append(entry, "!MISSING")
+	}
+
+	var sb strings.Builder
+	if sl.json {
+		sb.WriteByte('{')
+	}
+	for i := 0; i < len(entry); i += 2 {
+		key, value := fmt.Sprint(entry[i]), logValue(entry[i+1])
+		if sl.json {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			k, _ := json.Marshal(key)
+			v, err := json.Marshal(value)
+			if err != nil {
+				v, _ = json.Marshal(fmt.Sprint(value))
+			}
+			sb.Write(k)
+			sb.WriteByte(':')
+			sb.Write(v)
+			continue
+		}
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(key)
+		sb.WriteByte('=')
+		sb.WriteString(logfmtValue(fmt.Sprint(value)))
+	}
+	if sl.json {
+		sb.WriteByte('}')
+	}
+	sb.WriteByte('\n')
+
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	io.WriteString(sl.w, sb.String())
+}
+
+// logValue converts values which are encoded poorly by default, e.g. errors to their messages and data to strings
+func logValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case error:
+		return value.Error()
+	case []byte:
+		return string(value)
+	case fmt.Stringer:
+		return value.String()
+	}
+	return v
+}
+
+// logfmtValue quotes value if it has spaces, quotes or equal signs
+func logfmtValue(s string) string {
+	if s == "" || strings.ContainsAny(s, " =\"\t\n\\") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// newLogger builds default logger writing to `w` from `TalkerLogFormat` and `TalkerLogLevel`
+func (bt *Bottalker) newLogger(w io.Writer) (Logger, error) {
+	level := LogInfo
+	if bt.TalkerLogLevel != "" {
+		var err error
+		if level, err = ParseLogLevel(bt.TalkerLogLevel); err != nil {
+			return nil, err
+		}
+	}
+	switch strings.ToLower(bt.TalkerLogFormat) {
+	case "", "text":
+		return NewTextLogger(w, level), nil
+	case "json":
+		return NewJSONLogger(w, level), nil
+	}
+	return nil, fmt.Errorf("Unknown log format: %q", bt.TalkerLogFormat)
+}
+
+// initLogger sets logger used while running, returned func closes `TalkerLog` file if it's opened
+func (bt *Bottalker) initLogger() (func(), error) {
+	logger := bt.Logger
+	closeLog := func() {}
+	if logger == nil {
+		var w io.Writer = os.Stderr
+		if bt.TalkerLog != nil {
+			logPath := "./logs/bottalker.log"
+			if *bt.TalkerLog != "" {
+				logPath = *bt.TalkerLog
+			}
+			f, err := os.OpenFile(logPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+			if err != nil {
+				return nil, fmt.Errorf("Error opening file: %v", err)
+			}
+			w = f
+			closeLog = func() { f.Close() }
+		}
+		var err error
+		if logger, err = bt.newLogger(w); err != nil {
+			closeLog()
+			return nil, fmt.Errorf("Unable to init logger: %v", err)
+		}
+	}
+
+	bt.Lock()
+	bt.logger = logger.With("client", bt.TelegramClient.ID)
+	bt.Unlock()
+	return func() {
+		bt.Lock()
+		bt.logger = nil
+		bt.Unlock()
+		closeLog()
+	}, nil
+}
+
+// getLogger returns logger with client ID, `Logger` or default one is used unless Bottalker is running
+func (bt *Bottalker) getLogger() Logger {
+	bt.RLock()
+	defer bt.RUnlock()
+	if bt.logger != nil {
+		return bt.logger
+	}
+	logger := bt.Logger
+	if logger == nil {
+		logger = defaultLogger
+	}
+	if bt.TelegramClient == nil {
+		return logger
+	}
+	return logger.With("client", bt.TelegramClient.ID)
+}
@@ -0,0 +1,129 @@
+package bottalker_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+// syncBuffer is bytes.Buffer safe for concurrent use
+type syncBuffer struct {
+	buf bytes.Buffer
+	sync.Mutex
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.Lock()
+	defer sb.Unlock()
+	return sb.buf.String()
+}
+
+func TestTextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := bottalker.NewTextLogger(&buf, bottalker.LogInfo).With("bot", "QBot")
+	logger.Debug("Dropped")
+	logger.Info("Cooling down", "cooldown", 5*time.Minute, "rule", "wait 5 minutes")
+	logger.Error("Unable to notify", "error", errors.New("boom"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got:\n%s", buf.String())
+	}
+	for i, suffix := range []string{
+		`level=info msg="Cooling down" bot=QBot cooldown=5m0s rule="wait 5 minutes"`,
+		`level=error msg="Unable to notify" bot=QBot error=boom`,
+	} {
+		if !strings.HasPrefix(lines[i], "time=") || !strings.HasSuffix(lines[i], suffix) {
+			t.Errorf("line %d: got %s, expected suffix %s", i, lines[i], suffix)
+		}
+	}
+}
+
+func TestJSONLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := bottalker.NewJSONLogger(&buf, bottalker.LogDebug)
+	logger.With("bot", "QBot").Debug("Triggering command", "command", []byte("/bal_btc"), "position", 1)
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("unable to parse %s: %v", buf.String(), err)
+	}
+	for key, value := range map[string]interface{}{
+		"level":    "debug",
+		"msg":      "Triggering command",
+		"bot":      "QBot",
+		"command":  "/bal_btc",
+		"position": float64(1),
+	} {
+		if entry[key] != value {
+			t.Errorf("%s: got %v, expected %v", key, entry[key], value)
+		}
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	if ll, err := bottalker.ParseLogLevel("WARN"); err != nil || ll != bottalker.LogWarn {
+		t.Errorf("got %v, %v", ll, err)
+	}
+	if _, err := bottalker.ParseLogLevel("verbose"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+}
+
+func TestBottalkerLogger(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	qbot.OnText("/bal_btc").Reply("BTC: 1.0", nil)
+
+	var buf syncBuffer
+	startBottalker(t, &bottalker.Bottalker{
+		TelegramClient: &bottalker.TelegramClient{
+			ID:      "test",
+			Backend: srv,
+		},
+		Logger: bottalker.NewJSONLogger(&buf, bottalker.LogInfo),
+		Bots: []*bottalker.Bot{{
+			Label:       "QBot",
+			ChatID:      qbot.ID,
+			ChkInterval: 10 * time.Millisecond,
+			Commands: []bottalker.BotCommandType{
+				&bottalker.BotCommandChat{
+					BotCommand: bottalker.BotCommand{
+						Data:   []byte("/bal_btc"),
+						Repeat: bottalker.RepeatOnce,
+					},
+				},
+			},
+		}},
+	})
+
+	waitFor(t, 5*time.Second, func() bool {
+		return strings.Contains(buf.String(), "Command is done")
+	})
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("unable to parse %s: %v", line, err)
+		}
+		if entry["client"] != "test" {
+			t.Errorf("entry without client: %s", line)
+		}
+		if entry["msg"] == "Command is done" && (entry["bot"] != "QBot" || entry["command"] != "/bal_btc") {
+			t.Errorf("unexpected entry: %s", line)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package bottalker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// botRuntime holds goroutines and subscriptions of running bot
+type botRuntime struct {
+	ctx        context.Context    // bot context, canceled on stop
+	cancel     context.CancelFunc // stops bot goroutines
+	starting   sync.WaitGroup     // holds thread until bot is started, see `startBot`
+	wg         sync.WaitGroup     // holds thread until bot goroutines stop
+	handlersWg sync.WaitGroup     // holds thread until message handlers stop
+	receivers  []*EventReceiver   // message handler subscriptions, closed on stop
+	once       sync.Once
+}
+
+// stop cancels bot goroutines, waits for them and unsubscribes message handlers, concurrent calls wait for the first one
+func (rt *botRuntime) stop() {
+	rt.once.Do(func() {
+		rt.cancel()
+		rt.starting.Wait()
+		rt.wg.Wait()
+		for _, receiver := range rt.receivers {
+			receiver.Close()
+		}
+		rt.handlersWg.Wait()
+	})
+}
+
+// attachBot prepares bot runtime to be started by `startBot`, it must be called under lock,
+// so bots are never started twice and can be stopped while they're starting
+func (bt *Bottalker) attachBot(ctx context.Context, b *Bot) *botRuntime {
+	ctx, cancel := context.WithCancel(ctx)
+	rt := &botRuntime{ctx: ctx, cancel: cancel}
+	rt.starting.Add(1)
+	b.Lock()
+	b.runtime = rt
+	b.metrics = bt.metrics
+	b.logger = bt.logger.With("bot", b.Label)
+	b.store = bt.Store
+	// queues are ready before message handler starts, so early replies are queued for reactions
+	b.reactionCh = make(chan *reactionReply, 100)
+	b.answerCh = make(chan *answerReply, 100)
+	b.wakeCh = make(chan struct{}, 1)
+	b.Unlock()
+	return rt
+}
+
+// startBot subscribes bot to updates, initializes it and starts its goroutines until bot context is canceled or bot is removed;
+// it makes network calls, so it's called without lock
+func (bt *Bottalker) startBot(b *Bot, rt *botRuntime) {
+	defer rt.starting.Done()
+	ctx := rt.ctx
+	// message handler goes first, so no reply is missed
+	bt.initMessageHandler(ctx, b, rt)
+	b.initBot(ctx, bt.TelegramClient, bt.ErrChan)
+	if b.LogID != 0 && b.RepInterval > 0 {
+		rt.wg.Add(1)
+		go func() {
+			defer rt.wg.Done()
+			b.runReports(ctx, bt.ErrChan)
+		}()
+	}
+	rt.wg.Add(1)
+	go func() {
+		defer rt.wg.Done()
+		b.run(ctx, bt.ErrChan)
+	}()
+}
+
+// stopRuntime stops bot goroutines started by Bottalker, it does nothing if bot isn't started
+func (b *Bot) stopRuntime() {
+	b.RLock()
+	rt := b.runtime
+	b.RUnlock()
+	if rt != nil {
+		rt.stop()
+	}
+}
+
+// GetBot returns bot by label, nil if there is no such
+func (bt *Bottalker) GetBot(label string) *Bot {
+	bt.RLock()
+	defer bt.RUnlock()
+	for _, b := range bt.Bots {
+		if b.Label == label {
+			return b
+		}
+	}
+	return nil
+}
+
+// AddBot adds bot, it's started right away if Bottalker is running; bot labels should be unique
+func (bt *Bottalker) AddBot(b *Bot) error {
+	if b.Label == "" {
+		return fmt.Errorf("Unable to add bot: label is required")
+	}
+	bt.Lock()
+	for _, known := range bt.Bots {
+		if known == b || known.Label == b.Label {
+			bt.Unlock()
+			return fmt.Errorf("Unable to add bot: %s is already added", b.Label)
+		}
+	}
+	bt.Bots = append(bt.Bots, b)
+	var rt *botRuntime
+	if bt.ctx != nil && bt.ctx.Err() == nil {
+		bt.logger.Info("Adding bot", "bot", b.Label)
+		rt = bt.attachBot(bt.ctx, b)
+	}
+	bt.Unlock()
+
+	if rt != nil {
+		bt.startBot(b, rt)
+	}
+	return nil
+}
+
+// RemoveBot stops bot by label and removes it, ticker and update subscriptions are released before return
+func (bt *Bottalker) RemoveBot(label string) error {
+	bt.Lock()
+	var removed *Bot
+	for i, b := range bt.Bots {
+		if b.Label == label {
+			removed = b
+			bt.Bots = append(bt.Bots[:i:i], bt.Bots[i+1:]...)
+			break
+		}
+	}
+	bt.Unlock()
+	if removed == nil {
+		return fmt.Errorf("Unable to remove bot: %s not found", label)
+	}
+
+	bt.getLogger().Info("Removing bot", "bot", label)
+	removed.stopRuntime()
+	return nil
+}
+
+// PauseBot pauses command triggers of bot by label, replies are still received
+func (bt *Bottalker) PauseBot(label string) error {
+	b := bt.GetBot(label)
+	if b == nil {
+		return fmt.Errorf("Unable to pause bot: %s not found", label)
+	}
+	b.Pause()
+	return nil
+}
+
+// ResumeBot resumes command triggers of bot by label
+func (bt *Bottalker) ResumeBot(label string) error {
+	b := bt.GetBot(label)
+	if b == nil {
+		return fmt.Errorf("Unable to resume bot: %s not found", label)
+	}
+	b.Resume()
+	return nil
+}
+
+// Pause stops triggering commands and reactions until `Resume`, manual triggers still work
+func (b *Bot) Pause() {
+	b.Lock()
+	b.paused = true
+	b.Unlock()
+	b.saveState()
+}
+
+// Resume continues triggering commands after `Pause`
+func (b *Bot) Resume() {
+	b.Lock()
+	b.paused = false
+	b.Unlock()
+	b.saveState()
+	b.wake()
+}
+
+// IsPaused checks if bot is paused
+func (b *Bot) IsPaused() bool {
+	b.RLock()
+	defer b.RUnlock()
+	return b.paused
+}
+
+// wake makes bot goroutine to reconsider commands state, e.g. to plan just started scheduled command
+func (b *Bot) wake() {
+	b.RLock()
+	wakeCh := b.wakeCh
+	b.RUnlock()
+	select {
+	case wakeCh <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,171 @@
+package bottalker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+func TestAddRemoveBot(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	started := qbot.OnText("/bal_btc").Reply("BTC: 1.0", nil)
+	pbot := srv.AddBot(600120109, "PBot")
+	pbot.Send("Welcome", nil)
+	rule := pbot.OnText("/bal_gst").Reply("GST: 2.0", nil)
+
+	bt := runBottalker(t, srv, &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: 10 * time.Millisecond,
+		Commands:    chatCommand("/bal_btc"),
+	})
+	waitFor(t, 5*time.Second, func() bool {
+		return started.Hits() > 0
+	})
+	receivers := srv.Receivers()
+
+	if err := bt.AddBot(&bottalker.Bot{Label: "QBot", ChatID: pbot.ID}); err == nil {
+		t.Error("duplicate label accepted")
+	}
+	if err := bt.AddBot(&bottalker.Bot{
+		Label:       "PBot",
+		ChatID:      pbot.ID,
+		ChkInterval: 10 * time.Millisecond,
+		Commands:    chatCommand("/bal_gst"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 5*time.Second, func() bool {
+		return rule.Hits() > 0
+	})
+	if srv.Receivers() <= receivers {
+		t.Errorf("unexpected receivers after add: %d", srv.Receivers())
+	}
+
+	if err := bt.RemoveBot("PBot"); err != nil {
+		t.Fatal(err)
+	}
+	if srv.Receivers() != receivers {
+		t.Errorf("unexpected receivers after remove: %d", srv.Receivers())
+	}
+	hits := rule.Hits()
+	time.Sleep(50 * time.Millisecond)
+	if rule.Hits() != hits {
+		t.Error("removed bot is still triggering")
+	}
+	if bt.GetBot("PBot") != nil || bt.GetBot("QBot") == nil {
+		t.Errorf("unexpected bots: %+v", bt.Bots)
+	}
+	if err := bt.RemoveBot("PBot"); err == nil {
+		t.Error("error expected for unknown bot")
+	}
+}
+
+func TestPauseBot(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	rule := qbot.OnText("/bal_btc").Reply("BTC: 1.0", nil)
+	ping := qbot.OnText("/ping").Reply("pong", nil)
+
+	bcc := &bottalker.BotCommandChat{
+		BotCommand: bottalker.BotCommand{
+			Data:     []byte("/ping"),
+			Passive:  true,
+			Schedule: bottalker.Every(10 * time.Millisecond),
+		},
+	}
+	b := &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: 10 * time.Millisecond,
+		Commands:    append(chatCommand("/bal_btc"), bcc),
+	}
+	bt := runBottalker(t, srv, b)
+	waitFor(t, 5*time.Second, func() bool {
+		return rule.Hits() > 0
+	})
+
+	if err := bt.PauseBot("QBot"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	hits := rule.Hits()
+	time.Sleep(50 * time.Millisecond)
+	if rule.Hits() != hits || !b.Status().Paused {
+		t.Error("paused bot is still triggering")
+	}
+
+	// passive scheduled command is started while bot is paused, it's triggered after resume only
+	bcc.Start()
+	time.Sleep(30 * time.Millisecond)
+	if ping.Hits() != 0 {
+		t.Error("started command triggered on paused bot")
+	}
+	if err := bt.ResumeBot("QBot"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 5*time.Second, func() bool {
+		return rule.Hits() > hits && ping.Hits() > 0
+	})
+
+	bcc.Stop()
+	time.Sleep(30 * time.Millisecond)
+	pings := ping.Hits()
+	time.Sleep(50 * time.Millisecond)
+	if ping.Hits() != pings {
+		t.Error("stopped command is still triggering")
+	}
+}
+
+func TestAddBotUnlocked(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	started := qbot.OnText("/bal_btc").Reply("BTC: 1.0", nil)
+	pbot := srv.AddBot(600120109, "PBot")
+	// long history makes bot init slow, it's paged with pauses
+	for i := 0; i < 150; i++ {
+		pbot.Send("Welcome", nil)
+	}
+
+	bt := runBottalker(t, srv, &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: 10 * time.Millisecond,
+		Commands:    chatCommand("/bal_btc"),
+	})
+	waitFor(t, 5*time.Second, func() bool {
+		return started.Hits() > 0
+	})
+	added := make(chan error)
+	go func() {
+		added <- bt.AddBot(&bottalker.Bot{
+			Label:       "PBot",
+			ChatID:      pbot.ID,
+			ChkInterval: time.Hour,
+		})
+	}()
+	waitFor(t, 5*time.Second, func() bool {
+		return bt.GetBot("PBot") != nil
+	})
+
+	// other bots are managed while new one is initializing
+	start := time.Now()
+	if err := bt.PauseBot("QBot"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("pause is blocked by bot init for %v", elapsed)
+	}
+	if err := <-added; err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Error("bot init is expected to be slow")
+	}
+}
@@ -0,0 +1,292 @@
+package bottalker
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricVec is Prometheus metric family with labels: counter, gauge or histogram
+type metricVec struct {
+	name    string
+	help    string
+	kind    string             // `counter`, `gauge` or `histogram`
+	labels  []string           // label names
+	buckets []float64          // histogram only; upper bounds, sorted
+	series  map[string]*series // by label values
+	sync.Mutex
+}
+
+// series is single metric of family
+type series struct {
+	labelValues []string
+	value       float64  // counter or gauge value, histogram sum
+	count       uint64   // histogram only; observations count
+	buckets     []uint64 // histogram only; observations by bucket, not cumulative
+}
+
+func newMetricVec(kind string, name string, help string, labels ...string) *metricVec {
+	return &metricVec{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		series: make(map[string]*series),
+	}
+}
+
+func newHistogramVec(name string, help string, buckets []float64, labels ...string) *metricVec {
+	mv := newMetricVec("histogram", name, help, labels...)
+	mv.buckets = buckets
+	return mv
+}
+
+// get returns series by label values, it's created if needed; must be called under lock
+func (mv *metricVec) get(labelValues []string) *series {
+	key := strings.Join(labelValues, "\xff")
+	s, ok := mv.series[key]
+	if !ok {
+		s = &series{labelValues: labelValues}
+		if mv.kind == "histogram" {
+			s.buckets = make([]uint64, len(mv.buckets))
+		}
+		mv.series[key] = s
+	}
+	return s
+}
+
+// add increases counter or gauge
+func (mv *metricVec) add(v float64, labelValues ...string) {
+	mv.Lock()
+	defer mv.Unlock()
+	mv.get(labelValues).value += v
+}
+
+// set sets gauge
+func (mv *metricVec) set(v float64, labelValues ...string) {
+	mv.Lock()
+	defer mv.Unlock()
+	mv.get(labelValues).value = v
+}
+
+// setCurrent sets state gauge, so `state` is 1 and all other states are 0
+func (mv *metricVec) setCurrent(state string) {
+	mv.Lock()
+	defer mv.Unlock()
+	for _, s := range mv.series {
+		s.value = 0
+	}
+	mv.get([]string{state}).value = 1
+}
+
+// observe adds histogram observation
+func (mv *metricVec) observe(v float64, labelValues ...string) {
+	mv.Lock()
+	defer mv.Unlock()
+	s := mv.get(labelValues)
+	s.value += v
+	s.count++
+	if i := sort.SearchFloat64s(mv.buckets, v); i < len(mv.buckets) {
+		s.buckets[i]++
+	}
+}
+
+// write writes metric family in Prometheus text format, series are sorted by label values
+func (mv *metricVec) write(w io.Writer) {
+	mv.Lock()
+	defer mv.Unlock()
+	if len(mv.series) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", mv.name, mv.help, mv.name, mv.kind)
+
+	leNames := append(append([]string(nil), mv.labels...), "le")
+	keys := make([]string, 0, len(mv.series))
+	for key := range mv.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := mv.series[key]
+		labels := formatLabels(mv.labels, s.labelValues)
+		if mv.kind != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", mv.name, labels, formatValue(s.value))
+			continue
+		}
+		leValues := append(append([]string(nil), s.labelValues...), "")
+		var cumulative uint64
+		for i, le := range mv.buckets {
+			cumulative += s.buckets[i]
+			leValues[len(leValues)-1] = formatValue(le)
+			fmt.Fprintf(w, "%s_bucket%s %d\n", mv.name, formatLabels(leNames, leValues), cumulative)
+		}
+		leValues[len(leValues)-1] = "+Inf"
+		fmt.Fprintf(w, "%s_bucket%s %d\n", mv.name, formatLabels(leNames, leValues), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", mv.name, labels, formatValue(s.value))
+		fmt.Fprintf(w, "%s_count%s %d\n", mv.name, labels, s.count)
+	}
+}
+
+// labelEscaper escapes label values as Prometheus text format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names []string, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, labelEscaper.Replace(values[i]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// metrics holds bottalker metrics, nil metrics ignore all observations
+type metrics struct {
+	triggers        *metricVec // triggers by result
+	triggerDuration *metricVec // time Telegram takes to perform trigger
+	replies         *metricVec // replies by update type
+	replyLatency    *metricVec // time from trigger to the first reply
+	errors          *metricVec // bot errors by type
+	authState       *metricVec // current authorization state is 1, others are 0
+	connState       *metricVec // current connection state is 1, others are 0
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		triggers: newMetricVec("counter", "bottalker_triggers_total",
+			"Command triggers by result.", "bot", "command", "result"),
+		triggerDuration: newHistogramVec("bottalker_trigger_duration_seconds",
+			"Time spent on command trigger request.", []float64{.05, .1, .25, .5, 1, 2.5, 5, 10}, "bot", "command"),
+		replies: newMetricVec("counter", "bottalker_replies_total",
+			"Bot updates received, by tdlib update type.", "bot", "update"),
+		replyLatency: newHistogramVec("bottalker_reply_latency_seconds",
+			"Time from command trigger to the first bot reply.", []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60}, "bot", "command"),
+		errors: newMetricVec("counter", "bottalker_errors_total",
+			"Bot errors by type.", "bot", "type"),
+		authState: newMetricVec("gauge", "bottalker_authorization_state",
+			"Telegram client authorization state, current one is 1.", "state"),
+		connState: newMetricVec("gauge", "bottalker_connection_state",
+			"Telegram client connection state, current one is 1.", "state"),
+	}
+}
+
+// observeTrigger counts trigger finished with `bErr`
+func (m *metrics) observeTrigger(b *Bot, bct BotCommandType, start time.Time, bErr *BotError) {
+	if m == nil {
+		return
+	}
+	result := "ok"
+	if bErr != nil {
+		result = bErr.ErrType.String()
+	}
+	m.triggers.add(1, b.Label, string(bct.getData()), result)
+	m.triggerDuration.observe(time.Since(start).Seconds(), b.Label, string(bct.getData()))
+}
+
+// observeReply counts bot update, latency is observed for the first reply to command
+func (m *metrics) observeReply(b *Bot, update string, bct BotCommandType) {
+	if m == nil {
+		return
+	}
+	m.replies.add(1, b.Label, update)
+	if bct == nil {
+		return
+	}
+	if at := bct.getReport().takeTriggeredAt(); !at.IsZero() {
+		m.replyLatency.observe(time.Since(at).Seconds(), b.Label, string(bct.getData()))
+	}
+}
+
+// observeError counts bot error
+func (m *metrics) observeError(bErr *BotError) {
+	if m == nil {
+		return
+	}
+	label := ""
+	if bErr.Bot != nil {
+		label = bErr.Bot.Label
+	}
+	m.errors.add(1, label, bErr.ErrType.String())
+}
+
+// setAuthState marks `state` as current authorization state
+func (m *metrics) setAuthState(state string) {
+	if m == nil {
+		return
+	}
+	m.authState.setCurrent(state)
+}
+
+// setConnState marks `state` as current connection state
+func (m *metrics) setConnState(state string) {
+	if m == nil {
+		return
+	}
+	m.connState.setCurrent(state)
+}
+
+// write writes collected metrics and state of `bots` in Prometheus text format
+func (m *metrics) write(w io.Writer, bots []*Bot) {
+	paused := newMetricVec("gauge", "bottalker_bot_paused", "Bot is paused.", "bot")
+	cooldown := newMetricVec("gauge", "bottalker_bot_cooldown_seconds", "Remaining bot cooldown.", "bot")
+	active := newMetricVec("gauge", "bottalker_bot_active_commands", "Commands queued for trigger.", "bot")
+	for _, b := range bots {
+		bs := b.Status()
+		var running float64
+		for _, cs := range bs.Commands {
+			if cs.Running {
+				running++
+			}
+		}
+		var isPaused float64
+		if bs.Paused {
+			isPaused = 1
+		}
+		paused.set(isPaused, bs.Label)
+		cooldown.set(bs.Cooldown.Seconds(), bs.Label)
+		active.set(running, bs.Label)
+	}
+
+	for _, mv := range []*metricVec{m.triggers, m.triggerDuration, m.replies, m.replyLatency, m.errors, m.authState, m.connState, paused, cooldown, active} {
+		mv.write(w)
+	}
+}
+
+// getMetrics returns Bottalker metrics, they are created on the first call
+func (bt *Bottalker) getMetrics() *metrics {
+	bt.Lock()
+	defer bt.Unlock()
+	if bt.metrics == nil {
+		bt.metrics = newMetrics()
+	}
+	return bt.metrics
+}
+
+// MetricsHandler returns HTTP handler serving metrics in Prometheus text format, `Run` serves it on `MetricsAddr`
+func (bt *Bottalker) MetricsHandler() http.Handler {
+	m := bt.getMetrics()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bt.RLock()
+		bots := append([]*Bot(nil), bt.Bots...)
+		bt.RUnlock()
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.write(w, bots)
+	})
+}
+
+// getMetrics returns metrics of Bottalker running bot, nil if bot isn't started by Bottalker
+func (b *Bot) getMetrics() *metrics {
+	b.RLock()
+	defer b.RUnlock()
+	return b.metrics
+}
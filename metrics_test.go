@@ -0,0 +1,90 @@
+package bottalker_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+func TestMetrics(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	qbot.OnText("/bal_btc").Reply("BTC: 1.0", nil)
+	qbot.OnText("/bal_gst").Fail(errors.New("boom"))
+
+	bt := runBottalker(t, srv, &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: 10 * time.Millisecond,
+		RetryPolicy: &bottalker.RetryPolicy{MaxAttempts: 1},
+		Commands: []bottalker.BotCommandType{
+			&bottalker.BotCommandChat{
+				BotCommand: bottalker.BotCommand{
+					Data: []byte("/bal_btc"),
+				},
+			},
+			&bottalker.BotCommandChat{
+				BotCommand: bottalker.BotCommand{
+					Data:   []byte("/bal_gst"),
+					Repeat: bottalker.RepeatOnce,
+				},
+			},
+		},
+	})
+	api := httptest.NewServer(bt.MetricsHandler())
+	defer api.Close()
+
+	var metrics string
+	scrape := func() string {
+		resp, err := api.Client().Get(api.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+	waitFor(t, 5*time.Second, func() bool {
+		metrics = scrape()
+		return strings.Contains(metrics, "bottalker_reply_latency_seconds_count") &&
+			strings.Contains(metrics, `bottalker_errors_total{bot="QBot",type="fatal"}`)
+	})
+	for _, line := range []string{
+		"# TYPE bottalker_triggers_total counter",
+		`bottalker_triggers_total{bot="QBot",command="/bal_btc",result="ok"}`,
+		`bottalker_triggers_total{bot="QBot",command="/bal_gst",result="fatal"}`,
+		`bottalker_trigger_duration_seconds_bucket{bot="QBot",command="/bal_btc",le="+Inf"}`,
+		`bottalker_replies_total{bot="QBot",update="updateChatLastMessage"}`,
+		`bottalker_reply_latency_seconds_bucket{bot="QBot",command="/bal_btc",le="60"}`,
+		`bottalker_authorization_state{state="authorizationStateReady"} 1`,
+		`bottalker_bot_paused{bot="QBot"} 0`,
+		`bottalker_bot_active_commands{bot="QBot"} 1`,
+	} {
+		if !strings.Contains(metrics, line) {
+			t.Errorf("metrics don't contain %q:\n%s", line, metrics)
+		}
+	}
+
+	srv.SetConnectionState(tdlib.NewConnectionStateConnecting())
+	waitFor(t, 5*time.Second, func() bool {
+		metrics = scrape()
+		return strings.Contains(metrics, `bottalker_connection_state{state="connectionStateConnecting"} 1`)
+	})
+	srv.SetConnectionState(tdlib.NewConnectionStateReady())
+	waitFor(t, 5*time.Second, func() bool {
+		metrics = scrape()
+		return strings.Contains(metrics, `bottalker_connection_state{state="connectionStateReady"} 1`) &&
+			strings.Contains(metrics, `bottalker_connection_state{state="connectionStateConnecting"} 0`)
+	})
+}
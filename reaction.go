@@ -0,0 +1,220 @@
+package bottalker
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+)
+
+// DefaultReactionCooldown is how long reaction rule ignores replies after it fired by default
+const DefaultReactionCooldown = 5 * time.Second
+
+// ReactionRule performs action when bot reply matches all of rule conditions
+//
+// Conditions are optional, rule without any conditions matches every reply.
+// Actions are performed in the order they are declared here, from bot goroutine,
+// so they don't interfere with commands triggered by interval.
+// Replies received during `Cooldown` after rule fired are considered caused by its actions
+// and are ignored by this rule, so rule can't loop on its own replies
+type ReactionRule struct {
+	Match   *regexp.Regexp           // condition: reply text matches regexp
+	Button  string                   // condition: reply has inline or reply keyboard button with this caption
+	Content tdlib.MessageContentEnum // condition: reply content type, e.g. `tdlib.MessagePhotoType`
+	Trigger BotCommandType           // action: trigger command, it should be one of bot `Commands`, most likely passive one
+	Press   string                   // action: press inline or reply keyboard button with this caption on reply
+	Send    string                   // action: send text message
+
+	Cooldown time.Duration // how long rule ignores replies after it fired, `DefaultReactionCooldown` if zero, negative disables it
+
+	firedAt time.Time // when rule actions were finished, accessed from bot goroutine only
+}
+
+// reactionReply is reply queued for reactions, edited messages are queued by ID and fetched later
+type reactionReply struct {
+	msg      *tdlib.Message
+	editedID int64
+}
+
+// match checks reply against rule conditions
+func (rr *ReactionRule) match(msg *tdlib.Message) bool {
+	if rr.Match != nil && !matchText(rr.Match, msg) {
+		return false
+	}
+	if rr.Content != "" && (msg.Content == nil || msg.Content.GetMessageContentEnum() != rr.Content) {
+		return false
+	}
+	if rr.Button != "" && findButton(msg, rr.Button) == nil {
+		return false
+	}
+	return true
+}
+
+// cooling checks if rule fired recently, so reply is most likely caused by its own actions
+func (rr *ReactionRule) cooling() bool {
+	cd := rr.Cooldown
+	if cd == 0 {
+		cd = DefaultReactionCooldown
+	}
+	return cd > 0 && !rr.firedAt.IsZero() && time.Since(rr.firedAt) < cd
+}
+
+// queueReaction queues new bot messages and edits for reactions, they are dropped if queue is full
+func (b *Bot) queueReaction(msg *tdlib.TdMessage) {
+	if len(b.Reactions) == 0 {
+		return
+	}
+	var rr *reactionReply
+	switch update := (*msg).(type) {
+	case *tdlib.UpdateNewMessage:
+		if update.Message.IsOutgoing || !b.isBotSender(update.Message) {
+			return
+		}
+		rr = &reactionReply{msg: update.Message}
+	case *tdlib.UpdateMessageEdited:
+		rr = &reactionReply{editedID: update.MessageID}
+	default:
+		return
+	}
+
+	b.RLock()
+	reactionCh := b.reactionCh
+	b.RUnlock()
+	select {
+	case reactionCh <- rr:
+	default:
+		b.getLogger().Warn("Reactions queue is full, reply skipped")
+	}
+}
+
+// react performs actions of rules matching reply
+func (b *Bot) react(ctx context.Context, errCh chan<- *BotError, rr *reactionReply) {
+	msg := rr.msg
+	if msg == nil {
+		var err error
+		msg, err = b.getTelegramClient().Backend.GetMessage(b.ChatID, rr.editedID)
+		if err != nil {
+			b.sendError(ctx, errCh, &BotError{
+				Err:     fmt.Errorf("GetMessage [%d] failed: %s", rr.editedID, err),
+				ErrType: BotErrError,
+				Bot:     b,
+			})
+			return
+		}
+	}
+
+	for _, rule := range b.Reactions {
+		if !rule.match(msg) {
+			continue
+		}
+		if rule.cooling() {
+			b.getLogger().Debug("Reaction rule fired recently, reply skipped", "msg_id", msg.ID)
+			continue
+		}
+		if cd := b.GetCooldown(); cd > 0 {
+			b.getLogger().Info("Cooling down, reaction skipped", "cooldown", cd)
+			return
+		}
+		if rule.Trigger != nil {
+			if rule.Trigger.getBot() != b {
+				b.sendError(ctx, errCh, &BotError{
+					Err:         fmt.Errorf("Reaction command is not one of bot commands"),
+					ErrType:     BotErrError,
+					Bot:         b,
+					CommandType: rule.Trigger,
+				})
+			} else if _, bErr := b.trigger(ctx, rule.Trigger, errCh); bErr != nil {
+				b.sendError(ctx, errCh, bErr)
+			}
+		}
+		if rule.Press != "" {
+			if bErr := b.pressButton(msg, rule.Press); bErr != nil {
+				b.sendError(ctx, errCh, bErr)
+			}
+		}
+		if rule.Send != "" {
+			if bErr := b.sendText(rule.Send); bErr != nil {
+				b.sendError(ctx, errCh, bErr)
+			}
+		}
+		rule.firedAt = time.Now()
+	}
+}
+
+// sendText sends ad-hoc text message to bot
+func (b *Bot) sendText(text string) *BotError {
+	return b.adhoc(func() *BotError {
+		if _, err := b.getTelegramClient().sendText(b.ChatID, text); err != nil {
+			return &BotError{
+				Err:     fmt.Errorf("SendMessage [%s] failed: %s", text, err),
+				ErrType: BotErrFatal,
+				Bot:     b,
+			}
+		}
+		return nil
+	})
+}
+
+// pressButton presses inline or reply keyboard button with `caption` on message, it's ad-hoc trigger
+func (b *Bot) pressButton(msg *tdlib.Message, caption string) *BotError {
+	button := findButton(msg, caption)
+	if button == nil {
+		return &BotError{
+			Err:     fmt.Errorf("Button [%s] not found in message [%d]", caption, msg.ID),
+			ErrType: BotErrError,
+			Bot:     b,
+		}
+	}
+	if button.Kind == ButtonKeyboard {
+		return b.adhoc(func() *BotError {
+			_, bErr := b.sendKeyboardButton(nil, button)
+			return bErr
+		})
+	}
+	if !button.IsCallback() {
+		return &BotError{
+			Err:     fmt.Errorf("Button [%s] is %s button, it can't be pressed", caption, button.Kind),
+			ErrType: BotErrError,
+			Bot:     b,
+		}
+	}
+
+	return b.adhoc(func() *BotError {
+		start := time.Now()
+		tc := b.getTelegramClient()
+		answer, err := tc.Backend.GetCallbackQueryAnswer(b.ChatID, msg.ID, tdlib.NewCallbackQueryPayloadData(button.Payload))
+		if err != nil {
+			errType := BotErrError
+			if err.Error() == "timeout" {
+				errType = BotErrWarn
+			}
+			return &BotError{
+				Err:     fmt.Errorf("GetCallbackQueryAnswer [%s] failed: %s", button.Payload, err),
+				ErrType: errType,
+				Bot:     b,
+			}
+		}
+		m, err := tc.Backend.GetMessage(b.ChatID, msg.ID)
+		if err != nil {
+			return &BotError{
+				Err:     fmt.Errorf("GetMessage [%d] failed: %s", msg.ID, err),
+				ErrType: BotErrError,
+				Bot:     b,
+			}
+		}
+		b.handleAnswer(nil, m, answer, start)
+		return nil
+	})
+}
+
+// findButton returns message button with `caption`, nil if there is no such
+func findButton(msg *tdlib.Message, caption string) *MessageButton {
+	for _, btn := range GetMessageButtons(msg) {
+		if btn.Text == caption {
+			return btn
+		}
+	}
+	return nil
+}
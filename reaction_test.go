@@ -0,0 +1,90 @@
+package bottalker_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+func TestReactions(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	qbot.OnText("/start").Reply("Menu", bottalkertest.InlineKeyboard(
+		bottalkertest.Row(bottalkertest.CallbackButton("Balance", "bal")),
+	))
+	pressed := qbot.OnCallback("bal").Edit("Balance: 5", nil)
+	acked := qbot.OnText("/ack").Reply("ok", nil)
+
+	ack := &bottalker.BotCommandChat{
+		BotCommand: bottalker.BotCommand{
+			Data:    []byte("/ack"),
+			Passive: true,
+		},
+	}
+	runBottalker(t, srv, &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: 10 * time.Millisecond,
+		Commands: []bottalker.BotCommandType{
+			&bottalker.BotCommandChat{
+				BotCommand: bottalker.BotCommand{
+					Data: []byte("/start"),
+				},
+			},
+			ack,
+		},
+		Reactions: []*bottalker.ReactionRule{
+			{Button: "Balance", Press: "Balance"},
+			{Match: regexp.MustCompile(`^Balance: \d+$`), Trigger: ack, Send: "thanks"},
+		},
+	})
+
+	waitFor(t, 5*time.Second, func() bool {
+		for _, msg := range srv.Messages(qbot.ID) {
+			if text := bottalker.GetMessageText(msg); msg.IsOutgoing && text != nil && *text == "thanks" {
+				return true
+			}
+		}
+		return false
+	})
+	if pressed.Hits() == 0 || acked.Hits() == 0 {
+		t.Errorf("unexpected hits, pressed: %d, acked: %d", pressed.Hits(), acked.Hits())
+	}
+}
+
+func TestReactionLoop(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	qbot.OnText("/start").Reply("Hello", nil)
+	hi := qbot.OnText("/hi").Reply("Hello", nil)
+
+	runBottalker(t, srv, &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: 10 * time.Millisecond,
+		Commands: []bottalker.BotCommandType{
+			&bottalker.BotCommandChat{
+				BotCommand: bottalker.BotCommand{
+					Data:   []byte("/start"),
+					Repeat: bottalker.RepeatOnce,
+				},
+			},
+		},
+		Reactions: []*bottalker.ReactionRule{
+			{Match: regexp.MustCompile(`^Hello$`), Send: "/hi"},
+		},
+	})
+
+	waitFor(t, 5*time.Second, func() bool {
+		return hi.Hits() > 0
+	})
+	time.Sleep(100 * time.Millisecond)
+	if hits := hi.Hits(); hits != 1 {
+		t.Errorf("reaction looped on its own reply, hits: %d", hits)
+	}
+}
@@ -0,0 +1,87 @@
+package bottalker
+
+import (
+	"fmt"
+)
+
+// RepeatModeEnum is the way command is repeated until it stops
+//
+// Trigger is counted once it's finished, retries made by `RetryPolicy` are the same trigger
+type RepeatModeEnum int
+
+// Enum to switch command repetition
+const (
+	RepeatForever RepeatModeEnum = iota // command is triggered until it's stopped by retry policy or manually
+	RepeatOnce                          // command stops after the first trigger
+	RepeatTimes                         // command stops after `BotCommand.RepeatTimes` triggers
+	RepeatUntil                         // command stops when reply to it matches `BotCommand.RepeatUntil`
+)
+
+// String returns repeat mode name
+func (rm RepeatModeEnum) String() string {
+	switch rm {
+	case RepeatForever:
+		return "forever"
+	case RepeatOnce:
+		return "once"
+	case RepeatTimes:
+		return "times"
+	case RepeatUntil:
+		return "until"
+	}
+	return fmt.Sprintf("unknown(%d)", int(rm))
+}
+
+// countTrigger counts finished trigger and stops running command if it's repeated enough, true is returned then
+func (bc *BotCommand) countTrigger() bool {
+	bc.Lock()
+	defer bc.Unlock()
+	bc.triggers++
+	if !bc.running {
+		return false
+	}
+	switch bc.Repeat {
+	case RepeatOnce:
+		bc.running = false
+	case RepeatTimes:
+		bc.running = bc.triggers < bc.RepeatTimes
+	}
+	return !bc.running
+}
+
+// matchUntil stops RepeatUntil command if reply `text` matches its condition, true is returned then
+func (bc *BotCommand) matchUntil(text string) bool {
+	bc.Lock()
+	defer bc.Unlock()
+	if bc.Repeat != RepeatUntil || bc.RepeatUntil == nil || !bc.running {
+		return false
+	}
+	if !bc.RepeatUntil.MatchString(text) {
+		return false
+	}
+	bc.running = false
+	return true
+}
+
+// getTriggers returns number of finished triggers since command start
+func (bc *BotCommand) getTriggers() int {
+	bc.RLock()
+	defer bc.RUnlock()
+	return bc.triggers
+}
+
+// trackRepeat stops RepeatUntil command if reply matches, `bct` is nil for replies not caused by commands
+func (b *Bot) trackRepeat(bct BotCommandType, text string) {
+	if bct != nil && bct.matchUntil(text) {
+		b.commandDone(bct)
+	}
+}
+
+// commandDone logs command which is done repeating, bot keeps running idle if it was the last one
+func (b *Bot) commandDone(bct BotCommandType) {
+	logger := b.getLogger()
+	logger.Info("Command is done", "command", bct.getData(), "triggers", bct.getTriggers())
+	if !b.getAlive() {
+		logger.Info("No active commands left, idling")
+	}
+}
@@ -0,0 +1,128 @@
+package bottalker_test
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+func TestRepeatModes(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	once := qbot.OnText("/once").Reply("once", nil)
+	times := qbot.OnText("/times").Reply("times", nil)
+	until := qbot.OnText("/until").Reply("Status: done", nil)
+	forever := qbot.OnText("/forever").Reply("forever", nil)
+
+	command := func(data string, repeat bottalker.RepeatModeEnum) bottalker.BotCommand {
+		return bottalker.BotCommand{
+			Data:        []byte(data),
+			Repeat:      repeat,
+			RepeatTimes: 3,
+			RepeatUntil: regexp.MustCompile(`^Status: done$`),
+		}
+	}
+	b := &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: 10 * time.Millisecond,
+		Commands: []bottalker.BotCommandType{
+			&bottalker.BotCommandChat{BotCommand: command("/once", bottalker.RepeatOnce)},
+			&bottalker.BotCommandChat{BotCommand: command("/times", bottalker.RepeatTimes)},
+			&bottalker.BotCommandChat{BotCommand: command("/until", bottalker.RepeatUntil)},
+			&bottalker.BotCommandChat{BotCommand: command("/forever", bottalker.RepeatForever)},
+		},
+	}
+	runBottalker(t, srv, b)
+
+	waitFor(t, 5*time.Second, func() bool {
+		return forever.Hits() >= 10
+	})
+	if once.Hits() != 1 || times.Hits() != 3 || until.Hits() != 1 {
+		t.Errorf("unexpected hits, once: %d, times: %d, until: %d", once.Hits(), times.Hits(), until.Hits())
+	}
+	for i, cs := range b.Status().Commands {
+		if running := i == 3; cs.Running != running {
+			t.Errorf("[%s] unexpected running state: %v", cs.Data, cs.Running)
+		}
+	}
+}
+
+func TestRepeatIdle(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	qbot.OnText("/bal_btc").Reply("BTC: 1.0", nil)
+	passive := qbot.OnText("/start").Reply("Menu", nil)
+
+	start := &bottalker.BotCommandChat{
+		BotCommand: bottalker.BotCommand{
+			Data:    []byte("/start"),
+			Passive: true,
+		},
+	}
+	b := &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: 5 * time.Millisecond,
+		Commands: []bottalker.BotCommandType{
+			&bottalker.BotCommandChat{
+				BotCommand: bottalker.BotCommand{
+					Data:   []byte("/bal_btc"),
+					Repeat: bottalker.RepeatOnce,
+				},
+			},
+			start,
+		},
+	}
+	runBottalker(t, srv, b)
+
+	waitFor(t, 5*time.Second, func() bool {
+		return !b.Status().Commands[0].Running
+	})
+	// bot keeps serving manual triggers after the last active command is done
+	time.Sleep(50 * time.Millisecond)
+	if _, bErr := start.Trigger(); bErr != nil {
+		t.Fatal(bErr)
+	}
+	if hits := passive.Hits(); hits != 1 {
+		t.Errorf("unexpected hits: %d", hits)
+	}
+}
+
+func TestRepeatDirectTrigger(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	qbot.OnText("/times").Reply("times", nil)
+
+	cmd := &bottalker.BotCommandChat{
+		BotCommand: bottalker.BotCommand{
+			Data:        []byte("/times"),
+			Repeat:      bottalker.RepeatTimes,
+			RepeatTimes: 2,
+		},
+	}
+	b := &bottalker.Bot{
+		Label:    "QBot",
+		ChatID:   qbot.ID,
+		Commands: []bottalker.BotCommandType{cmd},
+	}
+	runBottalker(t, srv, b)
+
+	// triggers fail until bot is initialized, failed ones aren't started
+	waitFor(t, 5*time.Second, func() bool {
+		_, bErr := cmd.Trigger()
+		return bErr == nil
+	})
+	if _, bErr := cmd.Trigger(); bErr != nil {
+		t.Fatal(bErr)
+	}
+	if cs := b.Status().Commands[0]; cs.Triggers != 2 || cs.Running {
+		t.Errorf("direct triggers aren't counted: %+v", cs)
+	}
+}
@@ -0,0 +1,127 @@
+package bottalker
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+)
+
+// ReplyKindEnum is kind of bot reply
+type ReplyKindEnum int
+
+// Enum to tell replies apart
+const (
+	ReplyNew            ReplyKindEnum = iota // new bot message, `tdlib.UpdateChatLastMessage` or `tdlib.UpdateNewMessage`
+	ReplyEditedContent                       // bot edited message text, `tdlib.UpdateMessageContent`
+	ReplyEditedMarkup                        // bot edited message buttons, `tdlib.UpdateMessageEdited`
+	ReplyCallbackAnswer                      // bot answered inline button press, `tdlib.CallbackQueryAnswer`
+)
+
+// String returns reply kind name
+func (rk ReplyKindEnum) String() string {
+	switch rk {
+	case ReplyNew:
+		return "new"
+	case ReplyEditedContent:
+		return "edited content"
+	case ReplyEditedMarkup:
+		return "edited markup"
+	case ReplyCallbackAnswer:
+		return "callback answer"
+	}
+	return fmt.Sprintf("unknown(%d)", int(rk))
+}
+
+// Reply is bot update passed to `Bot.Replies`
+type Reply struct {
+	Bot         *Bot              // bot which replied
+	Command     BotCommandType    // command reply is attributed to, the last triggered one; nil if nothing is triggered yet
+	Kind        ReplyKindEnum     // what bot did
+	Message     *tdlib.Message    // new or edited message, message with pressed button for callback answers; nil if message can't be fetched
+	Text        string            // message text or callback answer text
+	Buttons     []*MessageButton  // buttons of `Message`
+	Fields      map[string]string // data extracted by `Command` extract rules, nil if nothing is extracted
+	TriggeredAt time.Time         // the last successful trigger of `Command`, zero if unknown
+	ReceivedAt  time.Time         // time reply was received
+	Update      tdlib.TdMessage   // raw tdlib update or `*tdlib.CallbackQueryAnswer`
+}
+
+// Latency returns time from command trigger to reply, zero if trigger time is unknown
+func (r *Reply) Latency() time.Duration {
+	if r.TriggeredAt.IsZero() {
+		return 0
+	}
+	return r.ReceivedAt.Sub(r.TriggeredAt)
+}
+
+// newReply builds reply from bot update, edited messages are fetched unless `msg` is given;
+// nil is returned for updates which aren't replies, like our own messages
+func (b *Bot) newReply(update tdlib.TdMessage, msg *tdlib.Message, bct BotCommandType) *Reply {
+	reply := &Reply{
+		Bot:        b,
+		Command:    bct,
+		Message:    msg,
+		ReceivedAt: time.Now(),
+		Update:     update,
+	}
+	switch u := update.(type) {
+	case *tdlib.UpdateChatLastMessage:
+		if u.LastMessage == nil || u.LastMessage.IsOutgoing {
+			return nil
+		}
+		reply.Kind = ReplyNew
+		reply.Message = u.LastMessage
+	case *tdlib.UpdateNewMessage:
+		if u.Message == nil || u.Message.IsOutgoing {
+			return nil
+		}
+		reply.Kind = ReplyNew
+		reply.Message = u.Message
+	case *tdlib.UpdateMessageContent:
+		reply.Kind = ReplyEditedContent
+		if reply.Message == nil {
+			reply.Message = b.fetchMessage(u.ChatID, u.MessageID)
+		}
+		if content, ok := u.NewContent.(*tdlib.MessageText); ok {
+			reply.Text = content.Text.Text
+		}
+	case *tdlib.UpdateMessageEdited:
+		reply.Kind = ReplyEditedMarkup
+		if reply.Message == nil {
+			reply.Message = b.fetchMessage(u.ChatID, u.MessageID)
+		}
+	case *tdlib.CallbackQueryAnswer:
+		reply.Kind = ReplyCallbackAnswer
+		reply.Text = u.Text
+	default:
+		return nil
+	}
+
+	if reply.Message != nil {
+		if reply.Text == "" && reply.Kind != ReplyCallbackAnswer {
+			if text := GetMessageText(reply.Message); text != nil {
+				reply.Text = *text
+			}
+		}
+		reply.Buttons = GetMessageButtons(reply.Message)
+	}
+	if bct != nil {
+		reply.TriggeredAt = bct.getReport().getLastTriggeredAt()
+	}
+	return reply
+}
+
+// fetchMessage returns message by ID, nil if it can't be fetched
+func (b *Bot) fetchMessage(chatID int64, messageID int64) *tdlib.Message {
+	tc := b.getTelegramClient()
+	if tc == nil {
+		return nil
+	}
+	msg, err := tc.Backend.GetMessage(chatID, messageID)
+	if err != nil {
+		b.getLogger().Warn("Unable to fetch edited message", "msg_id", messageID, "error", err)
+		return nil
+	}
+	return msg
+}
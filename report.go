@@ -0,0 +1,270 @@
+package bottalker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// botReport accumulates bot activity between reports
+type botReport struct {
+	since       time.Time                // start of reporting period
+	replies     int                      // replies received
+	errors      map[BotErrorTypeEnum]int // errors by type
+	lastCommand BotCommandType           // replies are attributed to the last triggered command
+	sync.Mutex
+}
+
+// commandReport accumulates command activity between reports
+type commandReport struct {
+	triggers    int               // times command was triggered
+	retries     int               // upcoming retries of the current trigger, they aren't counted again
+	lastReply   string            // kept between reports
+	lastResult  map[string]string // data extracted from the last reply, kept between reports
+	triggeredAt time.Time         // the last successful trigger, zero after the first reply to it
+	lastTrigger time.Time         // the last successful trigger, kept between reports
+	sync.Mutex
+}
+
+// getLastReply returns text of the last reply to command
+func (cr *commandReport) getLastReply() string {
+	cr.Lock()
+	defer cr.Unlock()
+	return cr.lastReply
+}
+
+// takeTriggeredAt returns time of the last successful trigger if it isn't replied yet
+func (cr *commandReport) takeTriggeredAt() time.Time {
+	cr.Lock()
+	defer cr.Unlock()
+	at := cr.triggeredAt
+	cr.triggeredAt = time.Time{}
+	return at
+}
+
+// getLastTriggeredAt returns time of the last successful trigger, zero if command isn't triggered yet
+func (cr *commandReport) getLastTriggeredAt() time.Time {
+	cr.Lock()
+	defer cr.Unlock()
+	return cr.lastTrigger
+}
+
+// getLastResult returns data extracted from the last reply to command
+func (cr *commandReport) getLastResult() map[string]string {
+	cr.Lock()
+	defer cr.Unlock()
+	if cr.lastResult == nil {
+		return nil
+	}
+	result := make(map[string]string, len(cr.lastResult))
+	for k, v := range cr.lastResult {
+		result[k] = v
+	}
+	return result
+}
+
+// trackTrigger counts finished command trigger for reports and repetition, retries of the same trigger are counted once
+func (b *Bot) trackTrigger(bct BotCommandType) {
+	cr := bct.getReport()
+	cr.Lock()
+	retry := cr.retries > 0
+	if retry {
+		cr.retries--
+	} else {
+		cr.triggers++
+	}
+	cr.Unlock()
+	if !retry && bct.countTrigger() {
+		b.commandDone(bct)
+	}
+}
+
+// setRetry marks the next command trigger as retry of the previous one, or clears the mark
+func (b *Bot) setRetry(bct BotCommandType, retry bool) {
+	cr := bct.getReport()
+	cr.Lock()
+	defer cr.Unlock()
+	cr.retries = 0
+	if retry {
+		cr.retries = 1
+	}
+}
+
+// trackReply counts reply and remembers it as the last reply to the last triggered command,
+// which is returned
+func (b *Bot) trackReply(text string) BotCommandType {
+	b.report.Lock()
+	b.report.replies++
+	bct := b.report.lastCommand
+	b.report.Unlock()
+
+	if bct != nil && text != "" {
+		cr := bct.getReport()
+		cr.Lock()
+		cr.lastReply = text
+		cr.Unlock()
+	}
+	return bct
+}
+
+// startTrigger marks trigger as being made and returns its start time, every trigger implementation
+// calls it before sending anything and `observeTrigger` once it's done
+func (b *Bot) startTrigger() time.Time {
+	b.Lock()
+	defer b.Unlock()
+	b.inFlight++
+	return time.Now()
+}
+
+// finishTrigger marks trigger started by `startTrigger` as done
+func (b *Bot) finishTrigger() {
+	b.Lock()
+	defer b.Unlock()
+	b.inFlight--
+	b.triggerSeq++
+}
+
+// adhoc makes ad-hoc trigger, like reaction or conversation message, which isn't one of bot commands;
+// it's neither attributed replies to nor measured, so it doesn't affect command reports and metrics
+func (b *Bot) adhoc(trigger func() *BotError) *BotError {
+	b.startTrigger()
+	defer b.finishTrigger()
+	return trigger()
+}
+
+// observeTrigger counts trigger, makes command the one replies are attributed to if trigger succeeded
+// and updates metrics, it's called by every trigger implementation
+func (b *Bot) observeTrigger(bct BotCommandType, start time.Time, bErr *BotError) {
+	b.finishTrigger()
+	b.trackTrigger(bct)
+	if bErr == nil {
+		cr := bct.getReport()
+		cr.Lock()
+		cr.triggeredAt = time.Now()
+		cr.lastTrigger = cr.triggeredAt
+		cr.Unlock()
+
+		b.report.Lock()
+		b.report.lastCommand = bct
+		b.report.Unlock()
+	}
+	b.getMetrics().observeTrigger(b, bct, start, bErr)
+	b.saveState()
+}
+
+// sendError counts error, notifies `NotifyID` chat on fatal ones and passes error to `errCh`
+// unless `ctx` is canceled or there is no `errCh`
+func (b *Bot) sendError(ctx context.Context, errCh chan<- *BotError, bErr *BotError) {
+	b.report.Lock()
+	if b.report.errors == nil {
+		b.report.errors = make(map[BotErrorTypeEnum]int)
+	}
+	b.report.errors[bErr.ErrType]++
+	b.report.Unlock()
+	b.getMetrics().observeError(bErr)
+	b.saveState()
+
+	if bErr.ErrType == BotErrFatal {
+		if err := b.notify(fmt.Sprintf("Fatal: %s", bErr)); err != nil {
+			b.getLogger().Error("Unable to notify", "error", err)
+		}
+	}
+	if errCh == nil {
+		return
+	}
+	select {
+	case errCh <- bErr:
+	case <-ctx.Done():
+	}
+}
+
+// notify sends text to `NotifyID` chat if it's defined
+func (b *Bot) notify(text string) error {
+	b.RLock()
+	tc := b.notifier
+	b.RUnlock()
+	if b.NotifyID == 0 || tc == nil {
+		return nil
+	}
+	_, err := tc.sendText(b.NotifyID, text)
+	return err
+}
+
+// genReport builds bot activity summary and starts new reporting period
+func (b *Bot) genReport() string {
+	now := time.Now()
+	b.report.Lock()
+	since := b.report.since
+	replies := b.report.replies
+	errors := b.report.errors
+	b.report.since = now
+	b.report.replies = 0
+	b.report.errors = nil
+	b.report.Unlock()
+
+	sb := &strings.Builder{}
+	if since.IsZero() {
+		fmt.Fprintf(sb, "%s report\n", b.Label)
+	} else {
+		fmt.Fprintf(sb, "%s report for %v\n", b.Label, now.Sub(since).Round(time.Millisecond))
+	}
+
+	var triggers int
+	commands := &strings.Builder{}
+	for _, bct := range b.Commands {
+		cr := bct.getReport()
+		cr.Lock()
+		triggers += cr.triggers
+		fmt.Fprintf(commands, "%s: %d triggers", bct.getData(), cr.triggers)
+		if cr.lastReply != "" {
+			fmt.Fprintf(commands, ", last reply: %q", cr.lastReply)
+		}
+		commands.WriteString("\n")
+		cr.triggers = 0
+		cr.Unlock()
+	}
+
+	fmt.Fprintf(sb, "Triggers: %d, replies: %d\n", triggers, replies)
+	fmt.Fprintf(sb, "Errors: %s %d, %s %d, %s %d\n",
+		BotErrWarn, errors[BotErrWarn],
+		BotErrError, errors[BotErrError],
+		BotErrFatal, errors[BotErrFatal],
+	)
+	if cd := b.GetCooldown(); cd > 0 {
+		fmt.Fprintf(sb, "Cooldown: %v\n", cd.Round(time.Second))
+	}
+	sb.WriteString(commands.String())
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// runReports sends report to `LogID` chat every `RepInterval` until `ctx` is canceled
+func (b *Bot) runReports(ctx context.Context, errCh chan<- *BotError) {
+	b.getLogger().Info("Reporting", "interval", b.RepInterval)
+	// reporting period may be restored from store
+	b.report.Lock()
+	if b.report.since.IsZero() {
+		b.report.since = time.Now()
+	}
+	b.report.Unlock()
+
+	ticker := time.NewTicker(b.RepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		report := b.genReport()
+		b.saveState()
+		if _, err := b.TelegramClient.sendText(b.LogID, report); err != nil {
+			b.sendError(ctx, errCh, &BotError{
+				Err:     fmt.Errorf("Unable to send report: %v", err),
+				ErrType: BotErrError,
+				Bot:     b,
+			})
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package bottalker_test
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+// lastText returns text of the latest chat message
+func lastText(srv *bottalkertest.Server, chatID int64) string {
+	msg := srv.LastMessage(chatID)
+	if msg == nil {
+		return ""
+	}
+	if text := bottalker.GetMessageText(msg); text != nil {
+		return *text
+	}
+	return ""
+}
+
+func TestReports(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	qbot.OnText("/bal_btc").Reply("BTC: 1.0", nil)
+	qbot.OnText("/bal_gst").Fail(errors.New("boom"))
+	logChat := srv.AddBot(700, "Log")
+	notifyChat := srv.AddBot(800, "Notify")
+
+	runBottalker(t, srv, &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: 10 * time.Millisecond,
+		LogID:       logChat.ID,
+		NotifyID:    notifyChat.ID,
+		RepInterval: 100 * time.Millisecond,
+		Commands: []bottalker.BotCommandType{
+			&bottalker.BotCommandChat{
+				BotCommand: bottalker.BotCommand{
+					Data:   []byte("/bal_btc"),
+					Repeat: bottalker.RepeatOnce,
+				},
+			},
+			&bottalker.BotCommandChat{
+				BotCommand: bottalker.BotCommand{
+					Data:   []byte("/bal_gst"),
+					Repeat: bottalker.RepeatOnce,
+				},
+			},
+		},
+	})
+
+	waitFor(t, 5*time.Second, func() bool {
+		return strings.Contains(lastText(srv, notifyChat.ID), "boom")
+	})
+	if text := lastText(srv, notifyChat.ID); !strings.HasPrefix(text, "Fatal: QBot > [/bal_gst]") {
+		t.Errorf("unexpected notification: %s", text)
+	}
+
+	waitFor(t, 5*time.Second, func() bool {
+		return strings.Contains(lastText(srv, logChat.ID), "fatal 1")
+	})
+	report := lastText(srv, logChat.ID)
+	for _, line := range []string{
+		"QBot report for",
+		"Errors: warn 0, error 0, fatal 1",
+		`last reply: "BTC: 1.0"`,
+	} {
+		if !strings.Contains(report, line) {
+			t.Errorf("report doesn't contain %q:\n%s", line, report)
+		}
+	}
+	// failed attempts are retries of single trigger
+	if n := reportedTriggers(srv, logChat.ID, "/bal_gst"); n != 1 {
+		t.Errorf("unexpected /bal_gst triggers reported: %d", n)
+	}
+}
+
+// reportedTriggers sums command triggers over all reports sent to chat
+func reportedTriggers(srv *bottalkertest.Server, chatID int64, data string) int {
+	re := regexp.MustCompile(regexp.QuoteMeta(data) + `: (\d+) triggers`)
+	var triggers int
+	for _, msg := range srv.Messages(chatID) {
+		if text := bottalker.GetMessageText(msg); text != nil {
+			if m := re.FindStringSubmatch(*text); m != nil {
+				n, _ := strconv.Atoi(m[1])
+				triggers += n
+			}
+		}
+	}
+	return triggers
+}
+
+func TestNotifyInitError(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	notifyChat := srv.AddBot(800, "Notify")
+
+	runBottalker(t, srv, &bottalker.Bot{
+		Label:    "QBot",
+		ChatID:   qbot.ID,
+		NotifyID: notifyChat.ID,
+		Commands: []bottalker.BotCommandType{
+			&bottalker.BotCommandButton{
+				Match: bottalker.CaptionRegexp,
+				BotCommand: bottalker.BotCommand{
+					Data: []byte("(unclosed"),
+				},
+			},
+		},
+	})
+
+	waitFor(t, 5*time.Second, func() bool {
+		return strings.Contains(lastText(srv, notifyChat.ID), "Unable to compile caption regexp")
+	})
+}
@@ -0,0 +1,141 @@
+package bottalker
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+)
+
+// RetryBehaviour is bot reaction on failed command trigger
+type RetryBehaviour int
+
+// Enum to switch retry behaviour
+const (
+	RetrySkip    RetryBehaviour = iota // report error and move on to the next command
+	RetryBackoff                       // retry trigger after backoff delay
+	RetryStop                          // report error and stop command
+)
+
+// RetryPolicy defines how bot retries failed command triggers
+//
+// Retries are performed inside bot goroutine, so other bots aren't delayed, but the affected bot's
+// reactions and scheduled commands wait for backoff delays as well
+type RetryPolicy struct {
+	MaxAttempts  int                                 // attempts including the first one, 1 disables retries
+	InitialDelay time.Duration                       // delay before the first retry
+	MaxDelay     time.Duration                       // delay limit, zero means no limit
+	Multiplier   float64                             // delay growth factor, 2 is used if not greater than 1
+	Jitter       float64                             // random delay deviation, e.g. 0.2 is ±20%
+	Behaviour    map[BotErrorTypeEnum]RetryBehaviour // reaction by error type, error types missing here are skipped
+}
+
+// DefaultRetryPolicy is used by bots without `RetryPolicy`
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts:  3,
+	InitialDelay: time.Second,
+	MaxDelay:     30 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+	Behaviour: map[BotErrorTypeEnum]RetryBehaviour{
+		BotErrWarn:  RetrySkip,
+		BotErrError: RetryBackoff,
+		BotErrFatal: RetryBackoff,
+	},
+}
+
+// delay returns backoff delay before `attempt` retry, starting from 1
+func (rp *RetryPolicy) delay(attempt int) time.Duration {
+	multiplier := rp.Multiplier
+	if multiplier <= 1 {
+		multiplier = 2
+	}
+	d := float64(rp.InitialDelay) * math.Pow(multiplier, float64(attempt-1))
+	if rp.MaxDelay > 0 && d > float64(rp.MaxDelay) {
+		d = float64(rp.MaxDelay)
+	}
+	if rp.Jitter > 0 {
+		d += d * rp.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+// behaviour returns reaction on error type
+func (rp *RetryPolicy) behaviour(errType BotErrorTypeEnum) RetryBehaviour {
+	if b, ok := rp.Behaviour[errType]; ok {
+		return b
+	}
+	return RetrySkip
+}
+
+// getRetryPolicy returns bot retry policy or the default one
+func (b *Bot) getRetryPolicy() *RetryPolicy {
+	if b.RetryPolicy != nil {
+		return b.RetryPolicy
+	}
+	return DefaultRetryPolicy
+}
+
+// trigger triggers command retrying it according to bot retry policy, retries are counted as the same trigger
+func (b *Bot) trigger(ctx context.Context, bct BotCommandType, errCh chan<- *BotError) (*tdlib.Message, *BotError) {
+	m, _, bErr := b.triggerWithAnswer(ctx, bct, errCh)
+	return m, bErr
+}
+
+// triggerWithAnswer is `trigger` returning callback answer as well, answer is nil unless command is CallbackCommand
+func (b *Bot) triggerWithAnswer(ctx context.Context, bct BotCommandType, errCh chan<- *BotError) (*tdlib.Message, *tdlib.CallbackQueryAnswer, *BotError) {
+	// attempt which fails before it's started doesn't take the mark, so it's cleared for the next trigger
+	defer b.setRetry(bct, false)
+	rp := b.getRetryPolicy()
+	for attempt := 1; ; attempt++ {
+		b.setRetry(bct, attempt > 1)
+		var m *tdlib.Message
+		var answer *tdlib.CallbackQueryAnswer
+		var bErr *BotError
+		if cc, ok := bct.(CallbackCommand); ok {
+			m, answer, bErr = cc.TriggerWithAnswer()
+		} else {
+			m, bErr = bct.Trigger()
+		}
+		if bErr == nil {
+			return m, answer, nil
+		}
+
+		// rate limit is handled by cooldown, retrying makes it worse
+		if d, ok := floodWait(bErr.Err); ok {
+			b.SetCooldown(d)
+			bErr.ErrType = BotErrWarn
+			bErr.Err = fmt.Errorf("%v; cooling down for %v", bErr.Err, d)
+			return nil, nil, bErr
+		}
+
+		switch rp.behaviour(bErr.ErrType) {
+		case RetryStop:
+			bct.Stop()
+			bErr.Err = fmt.Errorf("%v; command stopped", bErr.Err)
+			return nil, nil, bErr
+		case RetryBackoff:
+			if attempt >= rp.MaxAttempts {
+				bErr.Err = fmt.Errorf("%v; gave up after %d attempts", bErr.Err, attempt)
+				return nil, nil, bErr
+			}
+		default:
+			return nil, nil, bErr
+		}
+
+		// error isn't final until retries are exhausted, so it's reported as warning
+		d := rp.delay(attempt)
+		bErr.Err = fmt.Errorf("%s %v; retrying in %v", bErr.ErrType, bErr.Err, d)
+		bErr.ErrType = BotErrWarn
+		b.sendError(ctx, errCh, bErr)
+		b.getLogger().Info("Retrying", "command", bct.getData(), "attempt", attempt, "delay", d)
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+			return nil, nil, nil
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package bottalker_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+func chatCommand(data string) []bottalker.BotCommandType {
+	return []bottalker.BotCommandType{
+		&bottalker.BotCommandChat{
+			BotCommand: bottalker.BotCommand{
+				Data: []byte(data),
+			},
+		},
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	rule := qbot.OnText("/bal_btc").FailN(2, errors.New("error! code: 500 msg: Internal")).Reply("BTC: 1.0", nil)
+	logChat := srv.AddBot(700, "Log")
+
+	b := &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: 500 * time.Millisecond,
+		LogID:       logChat.ID,
+		RepInterval: 200 * time.Millisecond,
+		Commands: []bottalker.BotCommandType{
+			&bottalker.BotCommandChat{
+				BotCommand: bottalker.BotCommand{
+					Data:   []byte("/bal_btc"),
+					Repeat: bottalker.RepeatOnce,
+				},
+			},
+		},
+		RetryPolicy: &bottalker.RetryPolicy{
+			MaxAttempts:  3,
+			InitialDelay: 10 * time.Millisecond,
+			Behaviour: map[bottalker.BotErrorTypeEnum]bottalker.RetryBehaviour{
+				bottalker.BotErrFatal: bottalker.RetryBackoff,
+			},
+		},
+	}
+	start := time.Now()
+	runBottalker(t, srv, b)
+
+	waitFor(t, 5*time.Second, func() bool {
+		return lastText(srv, qbot.ID) == "BTC: 1.0"
+	})
+	// without retries the third attempt would be made on the third tick only
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("reply took too long: %v", elapsed)
+	}
+	if hits := rule.Hits(); hits != 3 {
+		t.Errorf("unexpected hits: %d", hits)
+	}
+	// attempts are retries of single trigger
+	waitFor(t, 5*time.Second, func() bool {
+		return strings.Contains(lastText(srv, logChat.ID), "BTC: 1.0")
+	})
+	if n := reportedTriggers(srv, logChat.ID, "/bal_btc"); n != 1 {
+		t.Errorf("unexpected triggers reported: %d", n)
+	}
+}
+
+func TestRetryIsolation(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	failing := srv.AddBot(600120108, "QBot")
+	failing.Send("Welcome", nil)
+	rule := failing.OnText("/bal_btc").Fail(errors.New("error! code: 500 msg: Internal"))
+
+	healthy := srv.AddBot(600120109, "PBot")
+	healthy.Send("Welcome", nil)
+	healthy.OnText("/bal_gst").Reply("GST: 2.0", nil)
+
+	replies := make(chan *bottalker.Reply, 100)
+	runBottalker(t, srv,
+		&bottalker.Bot{
+			Label:       "QBot",
+			ChatID:      failing.ID,
+			ChkInterval: 10 * time.Millisecond,
+			Commands:    chatCommand("/bal_btc"),
+			RetryPolicy: &bottalker.RetryPolicy{
+				MaxAttempts:  10,
+				InitialDelay: time.Hour,
+				Behaviour: map[bottalker.BotErrorTypeEnum]bottalker.RetryBehaviour{
+					bottalker.BotErrFatal: bottalker.RetryBackoff,
+				},
+			},
+		},
+		&bottalker.Bot{
+			Label:       "PBot",
+			ChatID:      healthy.ID,
+			ChkInterval: 10 * time.Millisecond,
+			Replies:     replies,
+			Commands:    chatCommand("/bal_gst"),
+		},
+	)
+
+	// healthy bot keeps working while failing one waits for retry
+	var got int
+	timeout := time.After(5 * time.Second)
+	for got < 3 {
+		select {
+		case <-replies:
+			got++
+		case <-timeout:
+			t.Fatalf("healthy bot is blocked, replies: %d", got)
+		}
+	}
+	if hits := rule.Hits(); hits != 1 {
+		t.Errorf("unexpected hits: %d", hits)
+	}
+}
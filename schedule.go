@@ -0,0 +1,315 @@
+package bottalker
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule decides when command is triggered, commands without schedule are triggered by bot `ChkInterval` in turn
+type Schedule interface {
+	Next(t time.Time) time.Time // returns trigger time following `t`, zero time if there is no such
+}
+
+// Every returns schedule triggering command each `d`, the first trigger happens in `d` after bot start
+func Every(d time.Duration) Schedule {
+	return &intervalSchedule{every: d}
+}
+
+// intervalSchedule triggers command with fixed delay
+type intervalSchedule struct {
+	every time.Duration
+}
+
+// Next returns `t` shifted by interval
+func (is *intervalSchedule) Next(t time.Time) time.Time {
+	if is.every <= 0 {
+		return time.Time{}
+	}
+	return t.Add(is.every)
+}
+
+// DailyAt returns schedule triggering command every day at `times` like `09:00` or `18:30:15` in `loc`, local time is used if `loc` is nil
+func DailyAt(loc *time.Location, times ...string) (Schedule, error) {
+	if len(times) == 0 {
+		return nil, fmt.Errorf("Unable to build daily schedule: no times specified")
+	}
+	if loc == nil {
+		loc = time.Local
+	}
+	ds := &dailySchedule{loc: loc}
+	for _, s := range times {
+		var at time.Time
+		var err error
+		if strings.Count(s, ":") == 2 {
+			at, err = time.Parse("15:04:05", s)
+		} else {
+			at, err = time.Parse("15:04", s)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse time of day %q: should be HH:MM or HH:MM:SS", s)
+		}
+		ds.times = append(ds.times, time.Duration(at.Hour())*time.Hour+
+			time.Duration(at.Minute())*time.Minute+time.Duration(at.Second())*time.Second)
+	}
+	sort.Slice(ds.times, func(i, j int) bool { return ds.times[i] < ds.times[j] })
+	return ds, nil
+}
+
+// dailySchedule triggers command at fixed times of day
+type dailySchedule struct {
+	loc   *time.Location
+	times []time.Duration // offsets from midnight, sorted
+}
+
+// Next returns the nearest time of day after `t`
+func (ds *dailySchedule) Next(t time.Time) time.Time {
+	t = t.In(ds.loc)
+	// two days are enough to find the next time, the third one covers DST shifts
+	for day := 0; day < 3; day++ {
+		for _, offset := range ds.times {
+			at := time.Date(t.Year(), t.Month(), t.Day()+day, 0, 0, int(offset/time.Second), 0, ds.loc)
+			if at.After(t) {
+				return at
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// ParseCron parses standard 5-field cron expression `minute hour day-of-month month day-of-week` evaluated in `loc`,
+// local time is used if `loc` is nil
+//
+// Fields support `*`, lists `1,15`, ranges `1-5`, steps `*/10` or `8-18/2` and month or weekday names `jan`, `mon`.
+// Shortcuts `@hourly`, `@daily` (`@midnight`), `@weekly`, `@monthly` and `@yearly` (`@annually`) are supported as well.
+// Like in cron, when both day of month and day of week are restricted, matching any of them is enough
+func ParseCron(expr string, loc *time.Location) (Schedule, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	if shortcut, ok := cronShortcuts[strings.ToLower(strings.TrimSpace(expr))]; ok {
+		expr = shortcut
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("Unable to parse cron %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	cs := &cronSchedule{loc: loc}
+	for i, field := range []struct {
+		bits     *uint64
+		min, max int
+		names    []string
+	}{
+		{bits: &cs.minute, min: 0, max: 59},
+		{bits: &cs.hour, min: 0, max: 23},
+		{bits: &cs.dom, min: 1, max: 31},
+		{bits: &cs.month, min: 1, max: 12, names: cronMonths},
+		{bits: &cs.dow, min: 0, max: 7, names: cronWeekdays},
+	} {
+		bits, err := parseCronField(fields[i], field.min, field.max, field.names)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to parse cron %q: field %d: %v", expr, i+1, err)
+		}
+		*field.bits = bits
+	}
+	// both 0 and 7 are Sunday
+	if cs.dow&(1<<7) != 0 {
+		cs.dow |= 1
+	}
+	cs.domAny = fields[2] == "*" || strings.HasPrefix(fields[2], "*/")
+	cs.dowAny = fields[4] == "*" || strings.HasPrefix(fields[4], "*/")
+	return cs, nil
+}
+
+var cronShortcuts = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+// names are indexed from field minimum
+var (
+	cronMonths   = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	cronWeekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// parseCronField returns bitmask of field values
+func parseCronField(field string, min, max int, names []string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			part = part[:i]
+		}
+
+		from, to := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			i := strings.Index(part, "-")
+			var err error
+			if from, err = parseCronValue(part[:i], min, max, names); err != nil {
+				return 0, err
+			}
+			if to, err = parseCronValue(part[i+1:], min, max, names); err != nil {
+				return 0, err
+			}
+			// Sunday ends weekday ranges like `mon-sun` as 7, it's folded into 0 later
+			if max == 7 && to == 0 && from > 0 {
+				to = 7
+			}
+			if from > to {
+				return 0, fmt.Errorf("bad range %q", part)
+			}
+		default:
+			v, err := parseCronValue(part, min, max, names)
+			if err != nil {
+				return 0, err
+			}
+			from = v
+			if step == 1 {
+				to = v
+			}
+		}
+
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// parseCronValue parses number or name of field value
+func parseCronValue(s string, min, max int, names []string) (int, error) {
+	for i, name := range names {
+		if strings.ToLower(s) == name {
+			return min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("value %q is out of range %d-%d", s, min, max)
+	}
+	return v, nil
+}
+
+// cronSchedule triggers command when time matches cron expression, with minute precision
+type cronSchedule struct {
+	loc                           *time.Location
+	minute, hour, dom, month, dow uint64 // bitmasks of allowed values
+	domAny, dowAny                bool   // day fields are not restricted
+}
+
+// matchDay checks day of month and day of week like cron does
+func (cs *cronSchedule) matchDay(t time.Time) bool {
+	domMatch := cs.dom&(1<<uint(t.Day())) != 0
+	dowMatch := cs.dow&(1<<uint(t.Weekday())) != 0
+	if cs.domAny || cs.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Next returns the first matching minute after `t`, zero time if nothing matches in 5 years
+func (cs *cronSchedule) Next(t time.Time) time.Time {
+	t = t.In(cs.loc)
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, cs.loc).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		var next time.Time
+		switch {
+		case cs.month&(1<<uint(t.Month())) == 0:
+			next = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, cs.loc)
+		case !cs.matchDay(t):
+			next = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, cs.loc)
+		case cs.hour&(1<<uint(t.Hour())) == 0:
+			next = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, cs.loc)
+		case cs.minute&(1<<uint(t.Minute())) == 0:
+			next = t.Add(time.Minute)
+		default:
+			return t
+		}
+		// DST transitions may turn wall clock back, time should always go forward
+		if !next.After(t) {
+			next = t.Add(time.Minute)
+		}
+		t = next
+	}
+	return time.Time{}
+}
+
+// scheduler tracks trigger times of scheduled commands, it's used from bot goroutine only
+type scheduler struct {
+	next  map[BotCommandType]time.Time // planned triggers of running scheduled commands
+	timer *time.Timer                  // fires at the earliest planned trigger
+}
+
+func newScheduler() *scheduler {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	return &scheduler{
+		next:  make(map[BotCommandType]time.Time),
+		timer: timer,
+	}
+}
+
+// plan schedules newly started commands, forgets stopped ones and arms timer for the earliest trigger
+func (s *scheduler) plan(commands []BotCommandType, now time.Time) {
+	var earliest time.Time
+	for _, bct := range commands {
+		schedule := bct.getSchedule()
+		if schedule == nil || !bct.isRunning() {
+			delete(s.next, bct)
+			continue
+		}
+		at, ok := s.next[bct]
+		if !ok {
+			at = schedule.Next(now)
+			s.next[bct] = at
+		}
+		if !at.IsZero() && (earliest.IsZero() || at.Before(earliest)) {
+			earliest = at
+		}
+	}
+
+	if !s.timer.Stop() {
+		select {
+		case <-s.timer.C:
+		default:
+		}
+	}
+	if !earliest.IsZero() {
+		s.timer.Reset(earliest.Sub(now))
+	}
+}
+
+// due returns commands which trigger time has come, in order of planned times, and plans their next triggers
+func (s *scheduler) due(now time.Time) []BotCommandType {
+	var due []BotCommandType
+	for bct, at := range s.next {
+		if !at.IsZero() && !at.After(now) {
+			due = append(due, bct)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return s.next[due[i]].Before(s.next[due[j]]) })
+	for _, bct := range due {
+		s.next[bct] = bct.getSchedule().Next(now)
+	}
+	return due
+}
+
+// stop releases timer
+func (s *scheduler) stop() {
+	s.timer.Stop()
+}
@@ -0,0 +1,104 @@
+package bottalker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+func TestParseCron(t *testing.T) {
+	// 2021-03-01 is Monday
+	from := time.Date(2021, 3, 1, 10, 17, 30, 0, time.UTC)
+	for expr, want := range map[string]time.Time{
+		"* * * * *":             time.Date(2021, 3, 1, 10, 18, 0, 0, time.UTC),
+		"*/15 * * * *":          time.Date(2021, 3, 1, 10, 30, 0, 0, time.UTC),
+		"0 9 * * *":             time.Date(2021, 3, 2, 9, 0, 0, 0, time.UTC),
+		"30 8-18/2 * * *":       time.Date(2021, 3, 1, 10, 30, 0, 0, time.UTC),
+		"0 0 * * fri":           time.Date(2021, 3, 5, 0, 0, 0, 0, time.UTC),
+		"0 0 * * 7":             time.Date(2021, 3, 7, 0, 0, 0, 0, time.UTC),
+		"0 0 * * mon-sun":       time.Date(2021, 3, 2, 0, 0, 0, 0, time.UTC),
+		"0 0 * * sat-sun":       time.Date(2021, 3, 6, 0, 0, 0, 0, time.UTC),
+		"0 0 * * 6-7":           time.Date(2021, 3, 6, 0, 0, 0, 0, time.UTC),
+		"0 9 * * 0-6/6":         time.Date(2021, 3, 6, 9, 0, 0, 0, time.UTC),
+		"0 0 13 * fri":          time.Date(2021, 3, 5, 0, 0, 0, 0, time.UTC),
+		"0 12 29 feb *":         time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC),
+		"@monthly":              time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC),
+		"0 0,12 1,15 jan-jun *": time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC),
+	} {
+		schedule, err := bottalker.ParseCron(expr, time.UTC)
+		if err != nil {
+			t.Errorf("%s: %v", expr, err)
+			continue
+		}
+		if next := schedule.Next(from); !next.Equal(want) {
+			t.Errorf("%s: unexpected next time %v, want %v", expr, next, want)
+		}
+	}
+
+	if berlin, err := time.LoadLocation("Europe/Berlin"); err == nil {
+		schedule, _ := bottalker.ParseCron("0 9 * * *", berlin)
+		if next := schedule.Next(from); !next.Equal(time.Date(2021, 3, 2, 8, 0, 0, 0, time.UTC)) {
+			t.Errorf("unexpected next time in timezone: %v", next)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "* * * foo *"} {
+		if _, err := bottalker.ParseCron(expr, nil); err == nil {
+			t.Errorf("%q: error expected", expr)
+		}
+	}
+}
+
+func TestDailyAt(t *testing.T) {
+	schedule, err := bottalker.DailyAt(time.UTC, "18:30", "09:00:15")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	next := schedule.Next(from)
+	if !next.Equal(time.Date(2021, 3, 1, 18, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected next time: %v", next)
+	}
+	if next = schedule.Next(next); !next.Equal(time.Date(2021, 3, 2, 9, 0, 15, 0, time.UTC)) {
+		t.Errorf("unexpected next time: %v", next)
+	}
+	if _, err := bottalker.DailyAt(nil, "25:00"); err == nil {
+		t.Error("error expected")
+	}
+}
+
+func TestScheduledCommands(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	ping := qbot.OnText("/ping").Reply("pong", nil)
+	report := qbot.OnText("/report").Reply("report", nil)
+
+	runBottalker(t, srv, &bottalker.Bot{
+		Label:  "QBot",
+		ChatID: qbot.ID,
+		Commands: []bottalker.BotCommandType{
+			&bottalker.BotCommandChat{
+				BotCommand: bottalker.BotCommand{
+					Data:     []byte("/ping"),
+					Schedule: bottalker.Every(20 * time.Millisecond),
+				},
+			},
+			&bottalker.BotCommandChat{
+				BotCommand: bottalker.BotCommand{
+					Data:     []byte("/report"),
+					Schedule: bottalker.Every(time.Hour),
+				},
+			},
+		},
+	})
+
+	waitFor(t, 5*time.Second, func() bool {
+		return ping.Hits() >= 3
+	})
+	if hits := report.Hits(); hits != 0 {
+		t.Errorf("unexpected report hits: %d", hits)
+	}
+}
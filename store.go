@@ -0,0 +1,527 @@
+package bottalker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultReplyLogLimit is how many replies FileStore keeps per bot by default
+const DefaultReplyLogLimit = 1000
+
+// DefaultSaveDelay is how long FileStore batches state changes by default
+const DefaultSaveDelay = time.Second
+
+// Store persists bot state between restarts, implement it to keep state in your own storage
+//
+// Bots save their state on every change and restore it on start, bots are matched by label
+// and commands by data, so state of renamed commands is lost; run state isn't restored if command
+// is made passive or active since it's saved. Store may buffer changes and hold resources,
+// Bottalker calls its `Flush() error` and `Close() error` methods on shutdown if there are such.
+type Store interface {
+	LoadBot(label string) (*BotState, error)             // saved bot state, nil if there is none
+	SaveBot(state *BotState) error                       // replace saved bot state
+	AppendReply(label string, record *ReplyRecord) error // add reply to bot reply log
+	ReplyLog(label string) ([]*ReplyRecord, error)       // bot reply log, oldest first
+}
+
+// BotState is persisted state of bot
+type BotState struct {
+	Label         string                   `json:"label"`
+	Paused        bool                     `json:"paused"`
+	CooldownUntil time.Time                `json:"cooldown_until"`
+	ReportSince   time.Time                `json:"report_since"` // start of current reporting period
+	Replies       int                      `json:"replies"`      // replies received in current reporting period
+	Errors        map[BotErrorTypeEnum]int `json:"errors"`       // errors by type in current reporting period
+	Commands      []*CommandState          `json:"commands"`
+	SavedAt       time.Time                `json:"saved_at"`
+}
+
+// CommandState is persisted state of bot command
+type CommandState struct {
+	Data        string            `json:"data"`
+	Running     bool              `json:"running"`
+	Passive     bool              `json:"passive"`          // `BotCommand.Passive` at save time, run state is restored only if it isn't changed
+	Triggers    int               `json:"triggers"`         // finished triggers, see `BotCommand.Repeat`
+	MsgID       int64             `json:"msg_id,omitempty"` // payload commands only; message with inline keyboard
+	LastReply   string            `json:"last_reply,omitempty"`
+	LastResult  map[string]string `json:"last_result,omitempty"`
+	LastTrigger time.Time         `json:"last_trigger,omitempty"`
+}
+
+// ReplyRecord is bot reply kept in reply log
+type ReplyRecord struct {
+	Time      time.Time `json:"time"`
+	Command   string    `json:"command,omitempty"` // data of command reply is attributed to
+	Kind      string    `json:"kind"`              // see ReplyKindEnum
+	MessageID int64     `json:"message_id,omitempty"`
+	Text      string    `json:"text"`
+}
+
+// FileStore is Store keeping bot states in JSON file and reply logs in append-only JSON lines file next to it
+//
+// State changes are batched and written `SaveDelay` after the first one, so the latest ones may be lost on crash,
+// use `Flush` to write them right away. Reply log is compacted on load and once it's twice as long as kept replies.
+type FileStore struct {
+	Path          string                    // state file path, parent directory is created if needed; reply log is `Path` + `.replies.jsonl`
+	ReplyLogLimit int                       // replies kept per bot, older ones are dropped; `DefaultReplyLogLimit` is used if zero
+	SaveDelay     time.Duration             // state changes are batched for that long; `DefaultSaveDelay` is used if zero
+	bots          map[string]*BotState      // saved bot states by label
+	replies       map[string][]*ReplyRecord // kept replies by bot label
+	logLines      int                       // records in reply log file, dropped ones included
+	logFile       *os.File                  // reply log opened for appending, nil until the first append
+	dirty         bool                      // states are changed since the last write
+	timer         *time.Timer               // pending state write, nil if there is none
+	saveErr       error                     // error of delayed state write, returned by the next `SaveBot`
+	sync.Mutex
+}
+
+// fileStoreData is FileStore state file content
+type fileStoreData struct {
+	Bots map[string]*BotState `json:"bots"`
+}
+
+// replyLogEntry is FileStore reply log line
+type replyLogEntry struct {
+	Label string `json:"bot"`
+	*ReplyRecord
+}
+
+// NewFileStore returns FileStore loaded from `path`, files are created on the first change if they don't exist
+func NewFileStore(path string) (*FileStore, error) {
+	fs := &FileStore{
+		Path:    path,
+		bots:    make(map[string]*BotState),
+		replies: make(map[string][]*ReplyRecord),
+	}
+	raw, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("Unable to read state file: %v", err)
+	default:
+		var data fileStoreData
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return nil, fmt.Errorf("Unable to parse state file %s: %v", path, err)
+		}
+		for label, state := range data.Bots {
+			fs.bots[label] = state
+		}
+	}
+	if err := fs.loadReplyLog(); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+// LoadBot returns saved bot state, nil if there is none
+func (fs *FileStore) LoadBot(label string) (*BotState, error) {
+	fs.Lock()
+	defer fs.Unlock()
+	return fs.bots[label], nil
+}
+
+// SaveBot replaces saved bot state, file is written after `SaveDelay`;
+// error of the previous delayed write is returned if there is one
+func (fs *FileStore) SaveBot(state *BotState) error {
+	fs.Lock()
+	defer fs.Unlock()
+	fs.bots[state.Label] = state
+	fs.dirty = true
+	if fs.timer == nil {
+		delay := fs.SaveDelay
+		if delay <= 0 {
+			delay = DefaultSaveDelay
+		}
+		fs.timer = time.AfterFunc(delay, fs.flushDelayed)
+	}
+	err := fs.saveErr
+	fs.saveErr = nil
+	return err
+}
+
+// Flush writes pending state changes right away
+func (fs *FileStore) Flush() error {
+	fs.Lock()
+	defer fs.Unlock()
+	if fs.timer != nil {
+		fs.timer.Stop()
+		fs.timer = nil
+	}
+	return fs.flush()
+}
+
+// Close flushes pending state changes and closes reply log, it's reopened on the next append
+func (fs *FileStore) Close() error {
+	err := fs.Flush()
+	fs.Lock()
+	defer fs.Unlock()
+	if fs.logFile != nil {
+		if cErr := fs.logFile.Close(); err == nil && cErr != nil {
+			err = fmt.Errorf("Unable to close reply log: %v", cErr)
+		}
+		fs.logFile = nil
+	}
+	return err
+}
+
+// flushDelayed writes pending state changes by timer
+func (fs *FileStore) flushDelayed() {
+	fs.Lock()
+	defer fs.Unlock()
+	fs.timer = nil
+	fs.saveErr = fs.flush()
+}
+
+// flush writes state file if states are changed; must be called under lock
+func (fs *FileStore) flush() error {
+	if !fs.dirty {
+		return nil
+	}
+	raw, err := json.MarshalIndent(&fileStoreData{Bots: fs.bots}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Unable to encode state: %v", err)
+	}
+	if err := writeFileAtomic(fs.Path, raw); err != nil {
+		return fmt.Errorf("Unable to write state: %v", err)
+	}
+	fs.dirty = false
+	return nil
+}
+
+// AppendReply adds reply to bot reply log and appends it to reply log file
+func (fs *FileStore) AppendReply(label string, record *ReplyRecord) error {
+	fs.Lock()
+	defer fs.Unlock()
+	fs.keepReply(label, record)
+	if fs.logLines+1 > 2*fs.keptReplies() {
+		return fs.compactReplyLog()
+	}
+
+	line, err := json.Marshal(&replyLogEntry{Label: label, ReplyRecord: record})
+	if err != nil {
+		return fmt.Errorf("Unable to encode reply: %v", err)
+	}
+	if fs.logFile == nil {
+		if err := os.MkdirAll(filepath.Dir(fs.Path), 0700); err != nil {
+			return fmt.Errorf("Unable to create state directory: %v", err)
+		}
+		if fs.logFile, err = os.OpenFile(fs.replyLogPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600); err != nil {
+			return fmt.Errorf("Unable to open reply log: %v", err)
+		}
+	}
+	if _, err := fs.logFile.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("Unable to write reply log: %v", err)
+	}
+	fs.logLines++
+	return nil
+}
+
+// ReplyLog returns bot reply log, oldest first
+func (fs *FileStore) ReplyLog(label string) ([]*ReplyRecord, error) {
+	fs.Lock()
+	defer fs.Unlock()
+	records := fs.replies[label]
+	if limit := fs.replyLogLimit(); len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+	return append([]*ReplyRecord(nil), records...), nil
+}
+
+// replyLogPath returns reply log file path
+func (fs *FileStore) replyLogPath() string {
+	return fs.Path + ".replies.jsonl"
+}
+
+// replyLogLimit returns replies kept per bot
+func (fs *FileStore) replyLogLimit() int {
+	if fs.ReplyLogLimit <= 0 {
+		return DefaultReplyLogLimit
+	}
+	return fs.ReplyLogLimit
+}
+
+// keepReply adds reply to kept ones dropping the oldest over limit; must be called under lock
+func (fs *FileStore) keepReply(label string, record *ReplyRecord) {
+	records := append(fs.replies[label], record)
+	if limit := fs.replyLogLimit(); len(records) > limit {
+		records = append([]*ReplyRecord(nil), records[len(records)-limit:]...)
+	}
+	fs.replies[label] = records
+}
+
+// keptReplies returns number of kept replies of all bots; must be called under lock
+func (fs *FileStore) keptReplies() int {
+	var n int
+	for _, records := range fs.replies {
+		n += len(records)
+	}
+	return n
+}
+
+// loadReplyLog reads reply log file, it's compacted if any replies are dropped
+func (fs *FileStore) loadReplyLog() error {
+	f, err := os.Open(fs.replyLogPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Unable to read reply log: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	var broken error
+	for scanner.Scan() {
+		// only the last line may be half-written, e.g. on crash
+		if broken != nil {
+			return fmt.Errorf("Unable to parse reply log %s: %v", fs.replyLogPath(), broken)
+		}
+		var entry replyLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			broken = err
+			continue
+		}
+		if entry.ReplyRecord == nil {
+			continue
+		}
+		fs.keepReply(entry.Label, entry.ReplyRecord)
+		fs.logLines++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("Unable to read reply log: %v", err)
+	}
+	if broken != nil || fs.logLines > fs.keptReplies() {
+		return fs.compactReplyLog()
+	}
+	return nil
+}
+
+// compactReplyLog replaces reply log file with kept replies; must be called under lock
+func (fs *FileStore) compactReplyLog() error {
+	if fs.logFile != nil {
+		fs.logFile.Close()
+		fs.logFile = nil
+	}
+	labels := make([]string, 0, len(fs.replies))
+	for label := range fs.replies {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, label := range labels {
+		for _, record := range fs.replies[label] {
+			if err := enc.Encode(&replyLogEntry{Label: label, ReplyRecord: record}); err != nil {
+				return fmt.Errorf("Unable to encode reply: %v", err)
+			}
+		}
+	}
+	if err := writeFileAtomic(fs.replyLogPath(), buf.Bytes()); err != nil {
+		return fmt.Errorf("Unable to write reply log: %v", err)
+	}
+	fs.logLines = fs.keptReplies()
+	return nil
+}
+
+// writeFileAtomic replaces file with `raw`, temporary file is synced and renamed so file is never half-written
+func writeFileAtomic(path string, raw []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("Unable to create directory: %v", err)
+	}
+	f, err := ioutil.TempFile(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(raw); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// closeStore writes pending changes of Bottalker store and releases its resources if it has such, like FileStore
+func (bt *Bottalker) closeStore() {
+	if fl, ok := bt.Store.(interface{ Flush() error }); ok {
+		if err := fl.Flush(); err != nil {
+			bt.getLogger().Error("Unable to flush store", "error", err)
+		}
+	}
+	if cl, ok := bt.Store.(interface{ Close() error }); ok {
+		if err := cl.Close(); err != nil {
+			bt.getLogger().Error("Unable to close store", "error", err)
+		}
+	}
+}
+
+// getStore returns Bottalker store, nil until bot is started by Bottalker with `Store`
+func (b *Bot) getStore() Store {
+	b.RLock()
+	defer b.RUnlock()
+	return b.store
+}
+
+// getState returns command state
+func (bc *BotCommand) getState() *CommandState {
+	bc.RLock()
+	cs := &CommandState{
+		Data:     string(bc.Data),
+		Running:  bc.running,
+		Passive:  bc.Passive,
+		Triggers: bc.triggers,
+	}
+	bc.RUnlock()
+
+	cr := &bc.report
+	cr.Lock()
+	defer cr.Unlock()
+	cs.LastReply = cr.lastReply
+	cs.LastResult = cr.lastResult
+	cs.LastTrigger = cr.lastTrigger
+	return cs
+}
+
+// setState restores command state, configuration wins over saved run state if command is made passive or active
+func (bc *BotCommand) setState(cs *CommandState) {
+	bc.Lock()
+	if cs.Passive == bc.Passive {
+		bc.running = cs.Running
+		bc.triggers = cs.Triggers
+	}
+	bc.Unlock()
+
+	cr := &bc.report
+	cr.Lock()
+	defer cr.Unlock()
+	cr.lastReply = cs.LastReply
+	cr.lastResult = cs.LastResult
+	cr.lastTrigger = cs.LastTrigger
+}
+
+// saveState saves bot state to store, it does nothing if there is no store
+func (b *Bot) saveState() {
+	store := b.getStore()
+	if store == nil {
+		return
+	}
+	// snapshots are saved one by one, so older one can't overwrite newer
+	b.stateMu.Lock()
+	defer b.stateMu.Unlock()
+
+	b.RLock()
+	state := &BotState{
+		Label:         b.Label,
+		Paused:        b.paused,
+		CooldownUntil: b.cooldownUntil,
+		SavedAt:       time.Now(),
+	}
+	b.RUnlock()
+
+	b.report.Lock()
+	state.ReportSince = b.report.since
+	state.Replies = b.report.replies
+	state.Errors = make(map[BotErrorTypeEnum]int, len(b.report.errors))
+	for errType, n := range b.report.errors {
+		state.Errors[errType] = n
+	}
+	b.report.Unlock()
+
+	for _, bct := range b.Commands {
+		cs := bct.getState()
+		if bcp, ok := bct.(*BotCommandPayload); ok {
+			bcp.RLock()
+			cs.MsgID = bcp.MsgID
+			bcp.RUnlock()
+		}
+		state.Commands = append(state.Commands, cs)
+	}
+	if err := store.SaveBot(state); err != nil {
+		b.getLogger().Error("Unable to save state", "error", err)
+	}
+}
+
+// loadState restores bot state from store, saved states of commands are returned
+// to be applied once commands are initialized
+func (b *Bot) loadState() map[BotCommandType]*CommandState {
+	store := b.getStore()
+	if store == nil {
+		return nil
+	}
+	state, err := store.LoadBot(b.Label)
+	if err != nil {
+		b.getLogger().Error("Unable to load state", "error", err)
+		return nil
+	}
+	if state == nil {
+		return nil
+	}
+	b.getLogger().Info("Restoring state", "saved_at", state.SavedAt)
+
+	b.Lock()
+	b.paused = state.Paused
+	b.cooldownUntil = state.CooldownUntil
+	b.Unlock()
+
+	b.report.Lock()
+	b.report.since = state.ReportSince
+	b.report.replies = state.Replies
+	b.report.errors = make(map[BotErrorTypeEnum]int, len(state.Errors))
+	for errType, n := range state.Errors {
+		b.report.errors[errType] = n
+	}
+	b.report.Unlock()
+
+	// commands are matched by data in order, so duplicates get their own states
+	states := make(map[BotCommandType]*CommandState, len(state.Commands))
+	used := make(map[*CommandState]bool, len(state.Commands))
+	for _, bct := range b.Commands {
+		for _, cs := range state.Commands {
+			if !used[cs] && cs.Data == string(bct.getData()) {
+				states[bct] = cs
+				used[cs] = true
+				break
+			}
+		}
+	}
+	return states
+}
+
+// logReply adds reply to store reply log, it does nothing if there is no store
+func (b *Bot) logReply(reply *Reply) {
+	store := b.getStore()
+	if store == nil {
+		return
+	}
+	record := &ReplyRecord{
+		Time: reply.ReceivedAt,
+		Kind: reply.Kind.String(),
+		Text: reply.Text,
+	}
+	if reply.Command != nil {
+		record.Command = string(reply.Command.getData())
+	}
+	if reply.Message != nil {
+		record.MessageID = reply.Message.ID
+	}
+	if err := store.AppendReply(b.Label, record); err != nil {
+		b.getLogger().Error("Unable to log reply", "error", err)
+	}
+}
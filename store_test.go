@@ -0,0 +1,220 @@
+package bottalker_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+func TestFileStoreRestart(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	welcome := qbot.Send("Balance", bottalkertest.InlineKeyboard(
+		bottalkertest.Row(bottalkertest.CallbackButton("BTC", "/bal_btc")),
+	))
+	qbot.OnCallback("/bal_btc").Answer("BTC: 1.0", false)
+	once := qbot.OnText("/once").Reply("done", nil)
+	path := filepath.Join(t.TempDir(), "state", "bottalker.json")
+
+	newBot := func() *bottalker.Bot {
+		return &bottalker.Bot{
+			Label:       "QBot",
+			ChatID:      qbot.ID,
+			ChkInterval: 10 * time.Millisecond,
+			Commands: []bottalker.BotCommandType{
+				&bottalker.BotCommandChat{
+					BotCommand: bottalker.BotCommand{
+						Data:   []byte("/once"),
+						Repeat: bottalker.RepeatOnce,
+					},
+				},
+				&bottalker.BotCommandPayload{
+					BotCommand: bottalker.BotCommand{
+						Data:    []byte("/bal_btc"),
+						Passive: true,
+					},
+				},
+			},
+		}
+	}
+	run := func(t *testing.T, b *bottalker.Bot) *bottalker.FileStore {
+		store, err := bottalker.NewFileStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		startBottalker(t, &bottalker.Bottalker{
+			TelegramClient: &bottalker.TelegramClient{
+				ID:      "test",
+				Backend: srv,
+			},
+			Bots:  []*bottalker.Bot{b},
+			Store: store,
+		})
+		return store
+	}
+
+	t.Run("first", func(t *testing.T) {
+		b := newBot()
+		run(t, b)
+		waitFor(t, 5*time.Second, func() bool {
+			return b.Status().Commands[0].LastReply == "done"
+		})
+		b.Pause()
+	})
+	// payload message ID must be restored, not resolved to the latest message again
+	qbot.Send("Newer message", nil)
+
+	t.Run("second", func(t *testing.T) {
+		b := newBot()
+		store := run(t, b)
+		waitFor(t, 5*time.Second, func() bool {
+			return b.IsPaused()
+		})
+		b.Resume()
+		time.Sleep(100 * time.Millisecond)
+
+		if hits := once.Hits(); hits != 1 {
+			t.Errorf("one-shot command is triggered again, hits: %d", hits)
+		}
+		cs := b.Status().Commands[0]
+		if cs.Running || cs.Triggers != 1 || cs.LastReply != "done" {
+			t.Errorf("unexpected restored command state: %+v", cs)
+		}
+		m, answer, bErr := b.Commands[1].(*bottalker.BotCommandPayload).TriggerWithAnswer()
+		if bErr != nil {
+			t.Fatal(bErr)
+		}
+		if m.ID != welcome.ID || answer.Text != "BTC: 1.0" {
+			t.Errorf("unexpected payload message: %d, answer: %+v", m.ID, answer)
+		}
+
+		records, err := store.ReplyLog("QBot")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) == 0 || records[0].Command != "/once" || records[0].Text != "done" || records[0].Kind != "new" {
+			t.Errorf("unexpected reply log: %+v", records)
+		}
+	})
+}
+
+func TestFileStoreReplyLogLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bottalker.json")
+	store, err := bottalker.NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.ReplyLogLimit = 2
+	for i := 1; i <= 10; i++ {
+		if err := store.AppendReply("QBot", &bottalker.ReplyRecord{Text: strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// log is appended, but compacted once it's twice as long as kept replies
+	raw, err := ioutil.ReadFile(path + ".replies.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(raw), "\n"); lines < 2 || lines > 4 {
+		t.Errorf("reply log isn't compacted, lines: %d", lines)
+	}
+
+	reloaded, err := bottalker.NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded.ReplyLogLimit = 2
+	records, _ := reloaded.ReplyLog("QBot")
+	if len(records) != 2 || records[0].Text != "9" || records[1].Text != "10" {
+		t.Errorf("unexpected reply log: %+v", records)
+	}
+}
+
+func TestFileStoreSaveDelay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bottalker.json")
+	store, err := bottalker.NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SaveDelay = time.Hour
+	for _, paused := range []bool{true, false, true} {
+		if err := store.SaveBot(&bottalker.BotState{Label: "QBot", Paused: paused}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("state is written before delay: %v", err)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := bottalker.NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state, _ := reloaded.LoadBot("QBot"); state == nil || !state.Paused {
+		t.Errorf("unexpected state: %+v", state)
+	}
+
+	// delayed write
+	reloaded.SaveDelay = 10 * time.Millisecond
+	reloaded.SaveBot(&bottalker.BotState{Label: "PBot"})
+	waitFor(t, 5*time.Second, func() bool {
+		raw, _ := ioutil.ReadFile(path)
+		return strings.Contains(string(raw), "PBot")
+	})
+}
+
+func TestFileStorePassiveChanged(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Welcome", nil)
+	rule := qbot.OnText("/start").Reply("Menu", nil)
+	store, err := bottalker.NewFileStore(filepath.Join(t.TempDir(), "bottalker.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// command was active when state was saved
+	store.SaveBot(&bottalker.BotState{
+		Label:    "QBot",
+		Commands: []*bottalker.CommandState{{Data: "/start", Running: true, Triggers: 5, LastReply: "Menu"}},
+	})
+
+	b := &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: 10 * time.Millisecond,
+		Commands: []bottalker.BotCommandType{
+			&bottalker.BotCommandChat{
+				BotCommand: bottalker.BotCommand{
+					Data:    []byte("/start"),
+					Passive: true,
+				},
+			},
+		},
+	}
+	startBottalker(t, &bottalker.Bottalker{
+		TelegramClient: &bottalker.TelegramClient{
+			ID:      "test",
+			Backend: srv,
+		},
+		Bots:  []*bottalker.Bot{b},
+		Store: store,
+	})
+	waitFor(t, 5*time.Second, func() bool {
+		return b.Status().Commands[0].LastReply == "Menu"
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	if cs := b.Status().Commands[0]; cs.Running || cs.Triggers != 0 || rule.Hits() != 0 {
+		t.Errorf("passive command is restored as running: %+v, hits: %d", cs, rule.Hits())
+	}
+}
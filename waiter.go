@@ -0,0 +1,202 @@
+package bottalker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Arman92/go-tdlib"
+)
+
+// replyWaiter collects chat updates while trigger is waiting for reply
+type replyWaiter struct {
+	updates chan *waiterUpdate
+}
+
+// waiterUpdate is chat update passed to waiter along with time it was received
+type waiterUpdate struct {
+	update     tdlib.TdMessage
+	receivedAt time.Time
+}
+
+// addWaiter subscribes waiter to bot chat updates, updates are delivered by message handler
+func (b *Bot) addWaiter() *replyWaiter {
+	rw := &replyWaiter{
+		updates: make(chan *waiterUpdate, 100),
+	}
+	b.Lock()
+	defer b.Unlock()
+	if b.waiters == nil {
+		b.waiters = make(map[*replyWaiter]struct{})
+	}
+	b.waiters[rw] = struct{}{}
+	return rw
+}
+
+// removeWaiter unsubscribes waiter
+func (b *Bot) removeWaiter(rw *replyWaiter) {
+	b.Lock()
+	defer b.Unlock()
+	delete(b.waiters, rw)
+}
+
+// offerReply passes chat update to waiting triggers, update is dropped for waiters which are full
+func (b *Bot) offerReply(msg *tdlib.TdMessage) {
+	wu := &waiterUpdate{
+		update:     *msg,
+		receivedAt: time.Now(),
+	}
+	b.RLock()
+	defer b.RUnlock()
+	for rw := range b.waiters {
+		select {
+		case rw.updates <- wu:
+		default:
+		}
+	}
+}
+
+// isSoleTrigger checks that waiter's trigger is the only one made since `seq` finished triggers and nothing is being triggered,
+// otherwise bot message which doesn't answer anything can't be correlated with trigger
+func (b *Bot) isSoleTrigger(seq uint64) bool {
+	b.RLock()
+	defer b.RUnlock()
+	return b.triggerSeq == seq && b.inFlight == 0 && len(b.waiters) == 1
+}
+
+// triggerAndWait triggers command and waits for bot's reply to it
+//
+// New message from bot is a reply if it answers trigger message; message which doesn't answer anything
+// is a reply only if it's sent after trigger and no other trigger is made meanwhile.
+// For payload and button commands edits of message with pressed button are replies as well
+func (b *Bot) triggerAndWait(ctx context.Context, bct BotCommandType, timeout time.Duration) (*Reply, *BotError) {
+	if b == nil || b.getTelegramClient() == nil {
+		return nil, &BotError{
+			Err:         fmt.Errorf("Bot is not initialized"),
+			ErrType:     BotErrFatal,
+			Bot:         b,
+			CommandType: bct,
+		}
+	}
+
+	// subscribing before trigger, reply may come before trigger returns
+	rw := b.addWaiter()
+	defer b.removeWaiter(rw)
+
+	b.RLock()
+	seq := b.triggerSeq + 1 // including our own trigger
+	b.RUnlock()
+	triggeredAt := time.Now()
+	m, bErr := bct.Trigger()
+	if bErr != nil {
+		return nil, bErr
+	}
+
+	var editID, sentID int64
+	sent := true
+	switch bct.(type) {
+	case *BotCommandPayload, *BotCommandButton:
+		editID = m.ID
+	default:
+		// pending message isn't on server yet, bot can't answer it
+		_, pending := m.SendingState.(*tdlib.MessageSendingStatePending)
+		sent = !pending
+		sentID = m.ID
+	}
+	triggerIDs := map[int64]bool{m.ID: true}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		var wu *waiterUpdate
+		select {
+		case wu = <-rw.updates:
+		case <-timer.C:
+			return nil, &BotError{
+				Err:         fmt.Errorf("No reply in %v", timeout),
+				ErrType:     BotErrWarn,
+				Bot:         b,
+				CommandType: bct,
+			}
+		case <-ctx.Done():
+			return nil, &BotError{
+				Err:         fmt.Errorf("Waiting for reply canceled: %v", ctx.Err()),
+				ErrType:     BotErrWarn,
+				Bot:         b,
+				CommandType: bct,
+			}
+		}
+
+		var msg *tdlib.Message
+		switch update := wu.update.(type) {
+		case *tdlib.UpdateMessageSendSucceeded:
+			// sent message gets its final ID once it reaches server, replies refer to that one
+			if triggerIDs[update.OldMessageID] {
+				triggerIDs[update.Message.ID] = true
+			}
+			if !sent && update.OldMessageID == sentID {
+				sent = true
+				sentID = update.Message.ID
+			}
+			continue
+		case *tdlib.UpdateNewMessage:
+			msg = update.Message
+			if msg.IsOutgoing || !b.isBotSender(msg) {
+				continue
+			}
+			if !triggerIDs[msg.ReplyToMessageID] &&
+				(msg.ReplyToMessageID != 0 || !sent || msg.ID <= sentID || !b.isSoleTrigger(seq)) {
+				continue
+			}
+		case *tdlib.UpdateMessageContent:
+			if editID == 0 || update.MessageID != editID {
+				continue
+			}
+		case *tdlib.UpdateMessageEdited:
+			if editID == 0 || update.MessageID != editID {
+				continue
+			}
+		default:
+			continue
+		}
+
+		if msg == nil {
+			// updates carry only changed part of edited message
+			if msg, bErr = b.getEdited(bct, editID); bErr != nil {
+				return nil, bErr
+			}
+		}
+		reply := b.newReply(wu.update, msg, bct)
+		reply.TriggeredAt = triggeredAt
+		reply.ReceivedAt = wu.receivedAt
+		reply.Fields = extract(bct.getExtractRules(), reply.Text)
+		return reply, nil
+	}
+}
+
+// getEdited returns edited message
+func (b *Bot) getEdited(bct BotCommandType, msgID int64) (*tdlib.Message, *BotError) {
+	m, err := b.getTelegramClient().Backend.GetMessage(b.ChatID, msgID)
+	if err != nil {
+		return nil, &BotError{
+			Err:         fmt.Errorf("GetMessage [%d] failed: %s", msgID, err),
+			ErrType:     BotErrError,
+			Bot:         b,
+			CommandType: bct,
+		}
+	}
+	return m, nil
+}
+
+// isBotSender checks that message is sent by bot, in private chat bot user ID is the chat ID
+// sender can't be checked in groups, so any incoming message is accepted there
+func (b *Bot) isBotSender(msg *tdlib.Message) bool {
+	if b.ChatID < 0 {
+		return true
+	}
+	sender, ok := msg.Sender.(*tdlib.MessageSenderUser)
+	if !ok {
+		return false
+	}
+	return int64(sender.UserID) == b.ChatID
+}
@@ -0,0 +1,126 @@
+package bottalker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cdtj/bottalker-go"
+	"github.com/cdtj/bottalker-go/bottalkertest"
+)
+
+func TestTriggerAndWait(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Balance", bottalkertest.InlineKeyboard(
+		bottalkertest.Row(bottalkertest.CallbackButton("GST", "/bal_gst")),
+	))
+	qbot.OnText("/bal_btc").Reply("BTC: 1.0", nil)
+	qbot.OnCallback("/bal_gst").Edit("GST: 2.0", nil)
+
+	bcc := &bottalker.BotCommandChat{
+		BotCommand: bottalker.BotCommand{
+			Data:    []byte("/bal_btc"),
+			Passive: true,
+		},
+	}
+	bcp := &bottalker.BotCommandPayload{
+		BotCommand: bottalker.BotCommand{
+			Data:    []byte("/bal_gst"),
+			Passive: true,
+		},
+	}
+	silent := &bottalker.BotCommandChat{
+		BotCommand: bottalker.BotCommand{
+			Data:    []byte("/unknown"),
+			Passive: true,
+		},
+	}
+	runBottalker(t, srv, &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: time.Hour,
+		Commands:    []bottalker.BotCommandType{bcc, bcp, silent},
+	})
+	// passive commands are linked with bot on start
+	waitFor(t, 5*time.Second, func() bool {
+		_, bErr := silent.TriggerAndWait(context.Background(), time.Millisecond)
+		return bErr == nil || bErr.Bot != nil
+	})
+
+	reply, bErr := bcc.TriggerAndWait(context.Background(), 5*time.Second)
+	if bErr != nil {
+		t.Fatal(bErr)
+	}
+	if reply.Text != "BTC: 1.0" || reply.Kind != bottalker.ReplyNew || reply.Latency() <= 0 || reply.Command != bcc {
+		t.Errorf("unexpected chat reply: %+v", reply)
+	}
+
+	reply, bErr = bcp.TriggerAndWait(context.Background(), 5*time.Second)
+	if bErr != nil {
+		t.Fatal(bErr)
+	}
+	if reply.Text != "GST: 2.0" || reply.Kind == bottalker.ReplyNew || reply.Message == nil {
+		t.Errorf("unexpected payload reply: %+v", reply)
+	}
+
+	_, bErr = silent.TriggerAndWait(context.Background(), 50*time.Millisecond)
+	if bErr == nil || bErr.ErrType != bottalker.BotErrWarn {
+		t.Errorf("timeout warning expected, got: %v", bErr)
+	}
+}
+
+func TestTriggerAndWaitOtherTrigger(t *testing.T) {
+	srv := bottalkertest.NewServer()
+	qbot := srv.AddBot(600120108, "QBot")
+	qbot.Send("Balance", bottalkertest.InlineKeyboard(
+		bottalkertest.Row(bottalkertest.CallbackButton("GST", "/bal_gst")),
+	))
+	// new message which doesn't answer anything
+	qbot.OnCallback("/bal_gst").Reply("GST: 2.0", nil)
+
+	bcp := &bottalker.BotCommandPayload{
+		BotCommand: bottalker.BotCommand{
+			Data:    []byte("/bal_gst"),
+			Passive: true,
+		},
+	}
+	silent := &bottalker.BotCommandChat{
+		BotCommand: bottalker.BotCommand{
+			Data:    []byte("/unknown"),
+			Passive: true,
+		},
+	}
+	runBottalker(t, srv, &bottalker.Bot{
+		Label:       "QBot",
+		ChatID:      qbot.ID,
+		ChkInterval: time.Hour,
+		Commands:    []bottalker.BotCommandType{bcp, silent},
+	})
+	waitFor(t, 5*time.Second, func() bool {
+		_, bErr := bcp.TriggerAndWait(context.Background(), time.Second)
+		return bErr == nil
+	})
+
+	// message caused by another trigger isn't a reply
+	done := make(chan *bottalker.BotError)
+	go func() {
+		_, bErr := silent.TriggerAndWait(context.Background(), 200*time.Millisecond)
+		done <- bErr
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if _, bErr := bcp.Trigger(); bErr != nil {
+		t.Fatal(bErr)
+	}
+	if bErr := <-done; bErr == nil || bErr.ErrType != bottalker.BotErrWarn {
+		t.Errorf("timeout warning expected, got: %v", bErr)
+	}
+
+	reply, bErr := bcp.TriggerAndWait(context.Background(), time.Second)
+	if bErr != nil {
+		t.Fatal(bErr)
+	}
+	if reply.Text != "GST: 2.0" || reply.Kind != bottalker.ReplyNew || reply.Message.ReplyToMessageID != 0 {
+		t.Errorf("unexpected reply: %+v", reply)
+	}
+}